@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+	"veritas-server/db"
+	"veritas-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	minCompareModels      = 2
+	maxCompareModels      = 6
+	defaultCompareTimeout = 60 * time.Second
+	maxCompareTimeout     = 5 * time.Minute
+)
+
+// CompareRequest represents a chat message sent to several models at once
+type CompareRequest struct {
+	ModelConfigIDs []string `json:"modelConfigIds" binding:"required"`
+	Message        string   `json:"message" binding:"required"`
+	ConversationID string   `json:"conversationId"`
+	TimeoutSeconds int      `json:"timeoutSeconds"` // Per-model timeout, default 60
+}
+
+// CompareAnswer is one model's answer in a comparison
+type CompareAnswer struct {
+	MessageID        uint   `json:"messageId"`
+	ModelConfigID    string `json:"modelConfigId"`
+	Response         string `json:"response"`
+	LatencyMs        int64  `json:"latencyMs"`
+	PromptTokens     int64  `json:"promptTokens"`
+	CompletionTokens int64  `json:"completionTokens"`
+	Error            string `json:"error,omitempty"`
+}
+
+// CompareResponse holds all answers of a comparison in request order
+type CompareResponse struct {
+	ConversationID string          `json:"conversationId"`
+	ComparisonID   string          `json:"comparisonId"`
+	UserMessageID  uint            `json:"userMessageId"`
+	Answers        []CompareAnswer `json:"answers"`
+}
+
+// ArenaVoteRequest represents a vote for the preferred answer of a comparison
+type ArenaVoteRequest struct {
+	ComparisonID    string `json:"comparisonId" binding:"required"`
+	WinnerMessageID *uint  `json:"winnerMessageId"` // Omit or null for a tie
+}
+
+// LeaderboardEntry summarizes the arena results of one model configuration
+type LeaderboardEntry struct {
+	ModelConfigID string  `json:"modelConfigId"`
+	Name          string  `json:"name"`
+	Battles       int     `json:"battles"`
+	Wins          int     `json:"wins"`
+	Losses        int     `json:"losses"`
+	Ties          int     `json:"ties"`
+	WinRate       float64 `json:"winRate"`
+	AvgLatencyMs  int64   `json:"avgLatencyMs"`
+}
+
+// CompareChat sends one user message to several model configurations concurrently.
+// Each answer is stored as a sibling reply to the message, together with its
+// latency and token usage, so the user can vote for the best one.
+func CompareChat(c *gin.Context) {
+	var req CompareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.ModelConfigIDs) < minCompareModels || len(req.ModelConfigIDs) > maxCompareModels {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Between 2 and 6 model configurations are required"})
+		return
+	}
+
+	var configCount int64
+	if err := db.DB.Model(&models.ModelConfig{}).Where("id IN ?", req.ModelConfigIDs).Count(&configCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load model configurations"})
+		return
+	}
+	if int(configCount) != len(req.ModelConfigIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or duplicate model configuration"})
+		return
+	}
+
+	timeout := defaultCompareTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = min(time.Duration(req.TimeoutSeconds)*time.Second, maxCompareTimeout)
+	}
+
+	chatReq := ChatRequest{
+		Message:        req.Message,
+		ConversationID: req.ConversationID,
+	}
+
+	// Create conversation if not provided
+	conversationID, err := ensureConversation(chatReq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create conversation"})
+		return
+	}
+	chatReq.ConversationID = conversationID
+
+	userMsg, err := saveUserMessage(chatReq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save message"})
+		return
+	}
+
+	// Fan out to every model, each with its own timeout
+	results := make([]llmResponse, len(req.ModelConfigIDs))
+	var wg sync.WaitGroup
+	for i, modelConfigID := range req.ModelConfigIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+			defer cancel()
+
+			modelReq := chatReq
+			modelReq.ModelConfigID = modelConfigID
+			results[i] = getLLMResponse(ctx, modelReq, userMsg)
+		}()
+	}
+	wg.Wait()
+
+	response := CompareResponse{
+		ConversationID: conversationID,
+		ComparisonID:   uuid.New().String(),
+		UserMessageID:  userMsg.ID,
+		Answers:        make([]CompareAnswer, len(results)),
+	}
+	for i, result := range results {
+		answer, err := saveComparisonAnswer(conversationID, response.ComparisonID, result, userMsg.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save response"})
+			return
+		}
+
+		response.Answers[i] = CompareAnswer{
+			MessageID:        answer.ID,
+			ModelConfigID:    answer.ModelConfigID,
+			Response:         answer.Content,
+			LatencyMs:        answer.LatencyMs,
+			PromptTokens:     answer.PromptTokens,
+			CompletionTokens: answer.CompletionTokens,
+		}
+		if result.Err != nil {
+			response.Answers[i].Error = result.Err.Error()
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// saveComparisonAnswer saves one answer of a comparison as a reply to the user message
+func saveComparisonAnswer(conversationID, comparisonID string, resp llmResponse, parentID uint) (*models.Message, error) {
+	answer := &models.Message{
+		ConversationID:   conversationID,
+		ParentID:         &parentID,
+		Role:             "assistant",
+		Content:          resp.Content,
+		ModelConfigID:    resp.ModelConfigID,
+		ComparisonID:     comparisonID,
+		LatencyMs:        resp.Latency.Milliseconds(),
+		PromptTokens:     resp.PromptTokens,
+		CompletionTokens: resp.CompletionTokens,
+		Failed:           resp.Err != nil,
+		CreatedAt:        time.Now(),
+	}
+	if err := appendMessage(answer); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// VoteArena records the preferred answer of a comparison and switches the
+// conversation to the winning answer's branch
+func VoteArena(c *gin.Context) {
+	var req ArenaVoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	var answers []models.Message
+	if err := db.DB.Where("comparison_id = ?", req.ComparisonID).Find(&answers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load comparison"})
+		return
+	}
+	if len(answers) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comparison not found"})
+		return
+	}
+
+	vote := models.ArenaVote{
+		ComparisonID:    req.ComparisonID,
+		ConversationID:  answers[0].ConversationID,
+		WinnerMessageID: req.WinnerMessageID,
+	}
+	if req.WinnerMessageID != nil {
+		found := false
+		for _, answer := range answers {
+			if answer.ID == *req.WinnerMessageID {
+				if answer.Failed {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "A failed answer cannot win"})
+					return
+				}
+				vote.WinnerModelConfigID = answer.ModelConfigID
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Winner is not an answer of this comparison"})
+			return
+		}
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "comparison_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"winner_message_id", "winner_model_config_id", "updated_at"}),
+		}).Create(&vote).Error; err != nil {
+			return err
+		}
+
+		if req.WinnerMessageID == nil {
+			return nil
+		}
+		return tx.Model(&models.Conversation{}).
+			Where("id = ?", vote.ConversationID).
+			Update("active_leaf_id", *req.WinnerMessageID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record vote"})
+		return
+	}
+
+	c.JSON(http.StatusOK, vote)
+}
+
+// GetArenaLeaderboard ranks model configurations by their arena win rate.
+// Failed answers, e.g. timeouts or provider errors, are not counted as battles
+// and do not affect the average latency.
+func GetArenaLeaderboard(c *gin.Context) {
+	// Deleted configurations keep their place on the leaderboard, so their
+	// names are joined without the soft delete filter
+	leaderboard := []LeaderboardEntry{}
+	if err := db.DB.Table("arena_votes AS v").
+		Select(`m.model_config_id,
+			COALESCE(MAX(mc.name), '') AS name,
+			COUNT(*) AS battles,
+			COUNT(*) FILTER (WHERE v.winner_message_id = m.id) AS wins,
+			COUNT(*) FILTER (WHERE v.winner_message_id <> m.id) AS losses,
+			COUNT(*) FILTER (WHERE v.winner_message_id IS NULL) AS ties,
+			COUNT(*) FILTER (WHERE v.winner_message_id = m.id)::float8 / COUNT(*) AS win_rate,
+			ROUND(AVG(m.latency_ms))::bigint AS avg_latency_ms`).
+		Joins("JOIN messages AS m ON m.comparison_id = v.comparison_id AND NOT m.failed").
+		Joins("LEFT JOIN model_configs AS mc ON mc.id = m.model_config_id").
+		Group("m.model_config_id").
+		Order("win_rate DESC, battles DESC").
+		Scan(&leaderboard).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load leaderboard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, leaderboard)
+}
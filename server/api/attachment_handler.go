@@ -0,0 +1,269 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"veritas-server/db"
+	"veritas-server/models"
+	"veritas-server/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxAttachmentSize limits the size of a single uploaded document
+	maxAttachmentSize = 20 << 20
+	// attachmentChunkSize and attachmentChunkOverlap control how documents are split, in characters
+	attachmentChunkSize    = 1500
+	attachmentChunkOverlap = 200
+	// maxAttachmentContext limits how many characters of document text are sent with a chat request
+	maxAttachmentContext = 12000
+)
+
+// UploadAttachments uploads one or more documents (multipart "file" fields) to
+// a conversation. Text is extracted and chunked on upload; the upload fails as
+// a whole if any file cannot be read.
+func UploadAttachments(c *gin.Context) {
+	var conv models.Conversation
+	if err := db.DB.First(&conv, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload at least one document in a multipart file field"})
+		return
+	}
+
+	var attachments []models.Attachment
+	var chunks []models.AttachmentChunk
+	for _, fileHeader := range form.File["file"] {
+		attachment, text, err := readAttachment(conv.ID, fileHeader)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %v", fileHeader.Filename, err)})
+			return
+		}
+
+		for i, content := range services.ChunkText(text, attachmentChunkSize, attachmentChunkOverlap) {
+			chunks = append(chunks, models.AttachmentChunk{
+				AttachmentID: attachment.ID,
+				Index:        i,
+				Content:      content,
+			})
+			attachment.ChunkCount++
+		}
+		attachments = append(attachments, *attachment)
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attachments).Error; err != nil {
+			return err
+		}
+		return tx.CreateInBatches(&chunks, 100).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachments"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachments)
+}
+
+// readAttachment reads an uploaded file and extracts its text
+func readAttachment(conversationID string, fileHeader *multipart.FileHeader) (*models.Attachment, string, error) {
+	if fileHeader.Size > maxAttachmentSize {
+		return nil, "", fmt.Errorf("file is larger than %d MB", maxAttachmentSize>>20)
+	}
+
+	contentType, ok := services.DocumentTypes[strings.ToLower(filepath.Ext(fileHeader.Filename))]
+	if !ok {
+		return nil, "", services.ErrUnsupportedDocument
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, "", errors.New("failed to read uploaded file")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAttachmentSize+1))
+	if err != nil {
+		return nil, "", errors.New("failed to read uploaded file")
+	}
+	if len(data) > maxAttachmentSize {
+		return nil, "", fmt.Errorf("file is larger than %d MB", maxAttachmentSize>>20)
+	}
+
+	text, err := services.ExtractDocumentText(fileHeader.Filename, data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &models.Attachment{
+		ID:             uuid.New().String(),
+		ConversationID: conversationID,
+		FileName:       filepath.Base(fileHeader.Filename),
+		ContentType:    contentType,
+		Size:           int64(len(data)),
+		CharCount:      utf8.RuneCountInString(text),
+	}, text, nil
+}
+
+// GetAttachments lists the documents attached to a conversation
+func GetAttachments(c *gin.Context) {
+	var attachments []models.Attachment
+	if err := db.DB.
+		Where("conversation_id = ?", c.Param("id")).
+		Order("created_at asc").
+		Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, attachments)
+}
+
+// DeleteAttachment removes a document and its chunks from a conversation
+func DeleteAttachment(c *gin.Context) {
+	var attachment models.Attachment
+	if err := db.DB.
+		Where("conversation_id = ? AND id = ?", c.Param("id"), c.Param("attachmentId")).
+		First(&attachment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("attachment_id = ?", attachment.ID).Delete(&models.AttachmentChunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&attachment).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted"})
+}
+
+// deleteConversationAttachments removes all attachments of a conversation
+func deleteConversationAttachments(tx *gorm.DB, conversationID string) error {
+	attachmentIDs := tx.Model(&models.Attachment{}).Select("id").Where("conversation_id = ?", conversationID)
+	if err := tx.Where("attachment_id IN (?)", attachmentIDs).Delete(&models.AttachmentChunk{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("conversation_id = ?", conversationID).Delete(&models.Attachment{}).Error
+}
+
+// attachmentChunk is a chunk together with the name of its document
+type attachmentChunk struct {
+	models.AttachmentChunk
+	FileName   string
+	ChunkCount int
+	position   int // Order of the chunk across all documents
+	score      int
+}
+
+// attachmentContext builds a system prompt containing the conversation's
+// documents. When they exceed maxAttachmentContext characters, the chunks
+// sharing the most words with query are chosen. Returns "" if there are no documents.
+func attachmentContext(conversationID, query string) (string, error) {
+	var chunks []attachmentChunk
+	if err := db.DB.
+		Table("attachment_chunks").
+		Select("attachment_chunks.*, attachments.file_name, attachments.chunk_count").
+		Joins("JOIN attachments ON attachments.id = attachment_chunks.attachment_id").
+		Where("attachments.conversation_id = ?", conversationID).
+		Order("attachments.created_at asc").
+		Order("attachment_chunks.index asc").
+		Scan(&chunks).Error; err != nil {
+		return "", err
+	}
+	if len(chunks) == 0 {
+		return "", nil
+	}
+
+	total := 0
+	for i := range chunks {
+		chunks[i].position = i
+		total += utf8.RuneCountInString(chunks[i].Content)
+	}
+
+	selected := chunks
+	if total > maxAttachmentContext {
+		terms := queryTerms(query)
+		for i := range chunks {
+			chunks[i].score = termScore(chunks[i].Content, terms)
+		}
+		sort.SliceStable(chunks, func(i, j int) bool {
+			return chunks[i].score > chunks[j].score
+		})
+
+		selected = nil
+		used := 0
+		for _, chunk := range chunks {
+			length := utf8.RuneCountInString(chunk.Content)
+			if used+length > maxAttachmentContext {
+				continue
+			}
+			selected = append(selected, chunk)
+			used += length
+		}
+		sort.Slice(selected, func(i, j int) bool {
+			return selected[i].position < selected[j].position
+		})
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("The user attached the following documents to this conversation. ")
+	prompt.WriteString("Use them when they are relevant to the question and name the document you rely on.")
+	if len(selected) < len(chunks) {
+		prompt.WriteString(" Only the excerpts most relevant to the latest question are included.")
+	}
+	for _, chunk := range selected {
+		fmt.Fprintf(&prompt, "\n\n<document name=%q part=\"%d/%d\">\n%s\n</document>",
+			chunk.FileName, chunk.Index+1, chunk.ChunkCount, chunk.Content)
+	}
+
+	return prompt.String(), nil
+}
+
+// queryTerms returns the distinct lowercase words of a query
+func queryTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(word) > 1 && !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// termScore rates how well text matches the terms: matching more distinct
+// terms counts more than repeating one of them
+func termScore(text string, terms []string) int {
+	text = strings.ToLower(text)
+	score := 0
+	for _, term := range terms {
+		if count := strings.Count(text, term); count > 0 {
+			score += 10 + min(count, 10)
+		}
+	}
+	return score
+}
@@ -2,11 +2,13 @@ package api
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 	"veritas-server/db"
 	"veritas-server/models"
+	"veritas-server/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -20,6 +22,10 @@ func Chat(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateMessageImages(req.ModelConfigID, req.ConversationID, req.ImageIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Create conversation if not provided
 	conversationID, err := ensureConversation(req)
@@ -29,23 +35,33 @@ func Chat(c *gin.Context) {
 	}
 	req.ConversationID = conversationID
 
+	if err := claimImages(req.ConversationID, req.ImageIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save images"})
+		return
+	}
+
 	// Save user message
-	if err := saveUserMessage(req); err != nil {
+	userMsg, err := saveUserMessage(req)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save message"})
 		return
 	}
 
-	// Get LLM response
-	responseContent := getLLMResponse(req)
+	// Get LLM response; without a chosen configuration, any healthy one may answer
+	req.AllowFallback = req.ModelConfigID == ""
+	resp := getLLMResponse(c.Request.Context(), req, userMsg)
 
 	// Save assistant message
-	if err := saveAssistantMessage(req.ConversationID, responseContent, req.ModelConfigID); err != nil {
+	if _, err := saveAssistantMessage(req.ConversationID, resp, userMsg.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save response"})
 		return
 	}
 
+	// Replace the placeholder title after the first exchange
+	maybeGenerateTitle(req)
+
 	c.JSON(http.StatusOK, ChatResponse{
-		Response:       responseContent,
+		Response:       resp.Content,
 		ConversationID: req.ConversationID,
 	})
 }
@@ -70,57 +86,118 @@ func ensureConversation(req ChatRequest) (string, error) {
 	return conv.ID, nil
 }
 
-// generateConversationTitle creates a title from the first message
-func generateConversationTitle(message string) string {
-	title := "New Chat"
-	if len(message) > 0 {
-		if len(message) > 30 {
-			title = message[:30] + "..."
-		} else {
-			title = message
-		}
+// maybeGenerateTitle starts asynchronous title generation if the conversation has
+// just completed its first exchange and still has a placeholder title
+func maybeGenerateTitle(req ChatRequest) {
+	var userMessages int64
+	if err := db.DB.Model(&models.Message{}).
+		Where("conversation_id = ? AND role = ?", req.ConversationID, "user").
+		Count(&userMessages).Error; err != nil || userMessages != 1 {
+		return
+	}
+
+	var conv models.Conversation
+	if err := db.DB.First(&conv, "id = ?", req.ConversationID).Error; err != nil {
+		return
+	}
+
+	if conv.Title == defaultConversationTitle || conv.Title == generateConversationTitle(req.Message) {
+		generateTitleAsync(conv.ID, req.ModelConfigID, conv.Title)
 	}
-	return title
 }
 
-// saveUserMessage saves the user's message to the database
-func saveUserMessage(req ChatRequest) error {
-	userMsg := models.Message{
+// saveUserMessage saves the user's message as a reply to the conversation's active leaf
+func saveUserMessage(req ChatRequest) (*models.Message, error) {
+	var conv models.Conversation
+	if err := db.DB.First(&conv, "id = ?", req.ConversationID).Error; err != nil {
+		return nil, err
+	}
+
+	userMsg := &models.Message{
 		ConversationID: req.ConversationID,
+		ParentID:       conv.ActiveLeafID,
 		Role:           "user",
 		Content:        req.Message,
 		ModelConfigID:  req.ModelConfigID,
+		ImageIDs:       req.ImageIDs,
 		CreatedAt:      time.Now(),
 	}
-	return db.DB.Create(&userMsg).Error
+	if err := appendMessage(userMsg); err != nil {
+		return nil, err
+	}
+	return userMsg, nil
 }
 
-// saveAssistantMessage saves the assistant's response to the database
-func saveAssistantMessage(conversationID, content, modelConfigID string) error {
-	assistantMsg := models.Message{
-		ConversationID: conversationID,
-		Role:           "assistant",
-		Content:        content,
-		ModelConfigID:  modelConfigID,
-		CreatedAt:      time.Now(),
+// saveAssistantMessage saves the assistant's response to the database as a reply to parentID
+func saveAssistantMessage(conversationID string, resp llmResponse, parentID uint) (*models.Message, error) {
+	assistantMsg := &models.Message{
+		ConversationID:   conversationID,
+		ParentID:         &parentID,
+		Role:             "assistant",
+		Content:          resp.Content,
+		ModelConfigID:    resp.ModelConfigID,
+		LatencyMs:        resp.Latency.Milliseconds(),
+		PromptTokens:     resp.PromptTokens,
+		CompletionTokens: resp.CompletionTokens,
+		Failed:           resp.Err != nil,
+		CreatedAt:        time.Now(),
 	}
-	return db.DB.Create(&assistantMsg).Error
+	if err := appendMessage(assistantMsg); err != nil {
+		return nil, err
+	}
+	return assistantMsg, nil
+}
+
+// llmResponse is the outcome of an LLM call. On failure, Content holds an error
+// message for the user, so it can still be stored and displayed as the answer.
+type llmResponse struct {
+	Content          string
+	ModelConfigID    string // Configuration used, after resolving the default
+	Latency          time.Duration
+	PromptTokens     int64
+	CompletionTokens int64
+	Err              error
 }
 
-// getLLMResponse calls the LLM API with full conversation history and returns the response
-func getLLMResponse(req ChatRequest) string {
+// getLLMResponse calls the LLM API with the conversation history and returns the response.
+// History is the branch ending with upTo, or the active branch if upTo is nil.
+func getLLMResponse(ctx context.Context, req ChatRequest, upTo *models.Message) llmResponse {
+	result := llmResponse{ModelConfigID: req.ModelConfigID}
+	fail := func(content string, err error) llmResponse {
+		result.Content = content
+		result.Err = err
+		return result
+	}
+
 	// Retrieve model configuration
 	var modelConfig models.ModelConfig
 	if req.ModelConfigID == "" {
 		// Try to get default model config
 		if err := db.DB.Where("is_default = ?", true).First(&modelConfig).Error; err != nil {
 			log.Printf("No model configuration specified and no default found: %v", err)
-			return "Error: No model configuration specified. Please select a model."
+			return fail("Error: No model configuration specified. Please select a model.", err)
 		}
 	} else {
 		if err := db.DB.First(&modelConfig, "id = ?", req.ModelConfigID).Error; err != nil {
 			log.Printf("Failed to load model configuration: %v", err)
-			return "Error: Invalid model configuration"
+			return fail("Error: Invalid model configuration", err)
+		}
+	}
+	result.ModelConfigID = modelConfig.ID
+	if !modelConfig.HasCapability(models.CapabilityChat) {
+		return fail("Error: "+modelConfig.Name+" is not a chat model. Please select another model.", errors.New("model configuration lacks the chat capability"))
+	}
+
+	// Load conversation history so the model has memory
+	history := loadConversationHistory(req.ConversationID, upTo)
+
+	// Answer with another configuration while health checks report this one
+	// down, keeping to vision models if the conversation has images
+	if req.AllowFallback && !configHealthy(modelConfig.ID) {
+		if fallback, ok := fallbackConfig(&modelConfig, hasImages(history)); ok {
+			log.Printf("Model config %s is unhealthy, falling back to %s", modelConfig.Name, fallback.Name)
+			modelConfig = *fallback
+			result.ModelConfigID = modelConfig.ID
 		}
 	}
 
@@ -128,25 +205,26 @@ func getLLMResponse(req ChatRequest) string {
 	client, err := createLLMClientFromConfig(&modelConfig, true) // true = decrypt API key
 	if err != nil {
 		log.Printf("Failed to create LLM client: %v", err)
-		return "Error: Failed to create LLM client. " + err.Error()
-	}
-
-	// Load full conversation history so the model has memory
-	var history []models.Message
-	if err := db.DB.
-		Where("conversation_id = ?", req.ConversationID).
-		Order("created_at asc").
-		Find(&history).Error; err != nil {
-		log.Printf("Failed to load conversation history: %v", err)
+		var decryptErr *services.DecryptionError
+		if errors.As(err, &decryptErr) {
+			return fail("Error: The API key for this model configuration cannot be decrypted. Please check the server's encryption key or re-enter the API key.", err)
+		}
+		return fail("Error: Failed to create LLM client. "+err.Error(), err)
 	}
 
 	var chatMessages []openai.ChatCompletionMessageParamUnion
 
+	images, err := loadImages(history)
+	if err != nil {
+		log.Printf("Failed to load images: %v", err)
+	}
+	vision := modelConfig.HasCapability(models.CapabilityVision)
+
 	// Convert stored messages into OpenAI chat messages
-	for _, m := range history {
+	for i, m := range history {
 		switch m.Role {
 		case "user":
-			chatMessages = append(chatMessages, openai.UserMessage(m.Content))
+			chatMessages = append(chatMessages, userChatMessage(&history[i], images, vision))
 		case "assistant":
 			chatMessages = append(chatMessages, openai.AssistantMessage(m.Content))
 		default:
@@ -159,18 +237,88 @@ func getLLMResponse(req ChatRequest) string {
 		chatMessages = append(chatMessages, openai.UserMessage(req.Message))
 	}
 
-	resp, err := client.Chat.Completions.New(
-		context.Background(),
-		openai.ChatCompletionNewParams{
-			Model:    openai.ChatModel(modelConfig.ModelID), //nolint:unconvert
-			Messages: chatMessages,
-		},
-	)
+	// Give the model the conversation's uploaded documents, focused on the latest question
+	query := req.Message
+	if len(history) > 0 && history[len(history)-1].Role == "user" {
+		query = history[len(history)-1].Content
+	}
+	documents, err := attachmentContext(req.ConversationID, query)
+	if err != nil {
+		log.Printf("Failed to load attachments: %v", err)
+	} else if documents != "" {
+		chatMessages = append([]openai.ChatCompletionMessageParamUnion{openai.SystemMessage(documents)}, chatMessages...)
+	}
+
+	params := openai.ChatCompletionNewParams{
+		Model:    openai.ChatModel(modelConfig.ModelID), //nolint:unconvert
+		Messages: chatMessages,
+	}
 
+	// Let the model search the knowledge base when there is one and the model can call tools
+	collections, err := searchableKnowledgeBases(req.KnowledgeBaseIDs)
 	if err != nil {
-		log.Printf("OpenAI API error: %v", err)
-		return "Error: Failed to get response from LLM provider. " + err.Error()
+		log.Printf("Failed to load knowledge bases: %v", err)
+	} else if len(collections) > 0 && !services.ResolveModelInfo(&modelConfig).SupportsToolCalling() {
+		log.Printf("Model %s does not support tool calling, not offering knowledge base search", modelConfig.ModelID)
+	} else if len(collections) > 0 {
+		params.Tools = []openai.ChatCompletionToolParam{knowledgeTool(collections)}
+	}
+
+	startTime := time.Now()
+	for round := 0; ; round++ {
+		if round == maxToolRounds {
+			// Out of tool rounds: the model has to answer with what it found
+			params.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String("none")}
+		}
+
+		resp, err := client.Chat.Completions.New(ctx, params)
+		result.Latency = time.Since(startTime)
+		if err != nil {
+			log.Printf("OpenAI API error: %v", err)
+			return fail("Error: Failed to get response from LLM provider. "+err.Error(), err)
+		}
+		if len(resp.Choices) == 0 {
+			return fail("Error: LLM provider returned an empty response.", errors.New("no choices in response"))
+		}
+		result.PromptTokens += resp.Usage.PromptTokens
+		result.CompletionTokens += resp.Usage.CompletionTokens
+
+		message := resp.Choices[0].Message
+		if len(message.ToolCalls) == 0 || round == maxToolRounds {
+			result.Content = message.Content
+			return result
+		}
+
+		params.Messages = append(params.Messages, message.ToParam())
+		for _, call := range message.ToolCalls {
+			params.Messages = append(params.Messages, openai.ToolMessage(runKnowledgeTool(ctx, call, collections), call.ID))
+		}
 	}
+}
 
-	return resp.Choices[0].Message.Content
+// loadConversationHistory returns the messages of the branch ending with upTo
+// (or the active branch if upTo is nil) in chronological order
+func loadConversationHistory(conversationID string, upTo *models.Message) []models.Message {
+	var leafID uint
+	if upTo != nil {
+		leafID = upTo.ID
+	} else {
+		var conv models.Conversation
+		if err := db.DB.First(&conv, "id = ?", conversationID).Error; err != nil {
+			log.Printf("Failed to load conversation: %v", err)
+			return nil
+		}
+		var err error
+		if leafID, err = activeLeaf(&conv); err != nil {
+			log.Printf("Failed to load conversation history: %v", err)
+			return nil
+		}
+	}
+
+	history, err := branchMessages(conversationID, leafID)
+	if err != nil {
+		log.Printf("Failed to load conversation history: %v", err)
+		return nil
+	}
+	return history
 }
@@ -1,20 +1,44 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 	"veritas-server/db"
 	"veritas-server/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// maxConversationTitleLength is the maximum title length in characters
+const maxConversationTitleLength = 200
+
+// ConversationResponse is a conversation with the most recent page of its messages
+type ConversationResponse struct {
+	models.Conversation
+	TotalMessages int64 `json:"totalMessages"`
+	// OlderMessagesCursor fetches earlier messages from GET /api/conversations/:id/messages?before=
+	OlderMessagesCursor string `json:"olderMessagesCursor,omitempty"`
+}
+
+// UpdateConversationRequest represents the request body for updating a conversation.
+// Only fields that are present are updated.
+type UpdateConversationRequest struct {
+	Title    *string `json:"title"`
+	Pinned   *bool   `json:"pinned"`
+	Archived *bool   `json:"archived"`
+}
+
 // CreateConversation creates a new conversation
 func CreateConversation(c *gin.Context) {
 	conv := models.Conversation{
 		ID:        uuid.New().String(),
-		Title:     "New Chat",
+		Title:     defaultConversationTitle,
 		CreatedAt: time.Now(),
 	}
 	if err := db.DB.Create(&conv).Error; err != nil {
@@ -24,23 +48,317 @@ func CreateConversation(c *gin.Context) {
 	c.JSON(http.StatusOK, conv)
 }
 
-// GetConversations returns all conversations ordered by creation time
+// GetConversations returns a page of conversations, newest first.
+// Query parameters:
+//   - limit, before, after: cursor pagination, see Page
+//   - archived: "true" for archived conversations only, "all" for both
+//     (default: not archived, or all when listing deleted conversations)
+//   - pinned: "true" or "false" to filter by pinned state
+//   - deleted: "true" to list soft-deleted conversations instead
 func GetConversations(c *gin.Context) {
-	var convs []models.Conversation
-	if err := db.DB.Order("created_at desc").Find(&convs).Error; err != nil {
+	query := db.DB.Model(&models.Conversation{})
+
+	deleted, _, err := queryBool(c, "deleted")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if deleted {
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+
+	switch archived := c.Query("archived"); archived {
+	case "all":
+	case "":
+		if !deleted {
+			query = query.Where("archived = ?", false)
+		}
+	default:
+		value, err := strconv.ParseBool(archived)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid archived filter: expected true, false or all"})
+			return
+		}
+		query = query.Where("archived = ?", value)
+	}
+
+	pinned, pinnedSet, err := queryBool(c, "pinned")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if pinnedSet {
+		query = query.Where("pinned = ?", pinned)
+	}
+
+	pageReq, err := parsePageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := fetchPage(query, pageReq, true, conversationCursor)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversations"})
 		return
 	}
-	c.JSON(http.StatusOK, convs)
+	c.JSON(http.StatusOK, page)
 }
 
-// GetConversation returns a specific conversation with its messages
+// GetConversation returns a specific conversation with the most recent messages of
+// its active branch. ?limit= sets how many messages are included; older ones are
+// available from GetMessages.
 func GetConversation(c *gin.Context) {
 	id := c.Param("id")
 	var conv models.Conversation
-	if err := db.DB.Preload("Messages").First(&conv, "id = ?", id).Error; err != nil {
+	if err := db.DB.First(&conv, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	pageReq, err := parsePageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pageReq.Before, pageReq.After = nil, nil
+
+	page, err := activeBranchPage(&conv, pageReq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+
+	conv.Messages = page.Items
+	c.JSON(http.StatusOK, ConversationResponse{
+		Conversation:        conv,
+		TotalMessages:       page.Total,
+		OlderMessagesCursor: page.BeforeCursor,
+	})
+}
+
+// GetMessages returns a page of the messages on a conversation's active branch in
+// chronological order. Without a cursor the most recent messages are returned;
+// pass ?before= to load older ones. With ?all=true, messages of every branch are
+// listed instead, and clients can rebuild the tree from their parentId.
+func GetMessages(c *gin.Context) {
+	id := c.Param("id")
+	var conv models.Conversation
+	if err := db.DB.First(&conv, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	pageReq, err := parsePageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	all, _, err := queryBool(c, "all")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if all {
+		page, err := fetchPage(db.DB.Model(&models.Message{}).Where("conversation_id = ?", id), pageReq, false, messageCursor)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+			return
+		}
+		c.JSON(http.StatusOK, page)
+		return
+	}
+
+	page, err := activeBranchPage(&conv, pageReq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func conversationCursor(conv models.Conversation) pageCursor {
+	return pageCursor{CreatedAt: conv.CreatedAt, ID: conv.ID}
+}
+
+func messageCursor(msg models.Message) pageCursor {
+	return pageCursor{CreatedAt: msg.CreatedAt, ID: strconv.FormatUint(uint64(msg.ID), 10)}
+}
+
+// UpdateConversation renames, pins/unpins or archives/unarchives a conversation
+func UpdateConversation(c *gin.Context) {
+	id := c.Param("id")
+
+	var req UpdateConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	var conv models.Conversation
+	if err := db.DB.First(&conv, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Title cannot be empty"})
+			return
+		}
+		if utf8.RuneCountInString(title) > maxConversationTitleLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Title is too long"})
+			return
+		}
+		updates["title"] = title
+	}
+	if req.Pinned != nil {
+		updates["pinned"] = *req.Pinned
+	}
+	if req.Archived != nil {
+		updates["archived"] = *req.Archived
+	}
+
+	if len(updates) > 0 {
+		if err := db.DB.Model(&conv).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update conversation"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, conv)
+}
+
+// RegenerateConversationTitleRequest represents the optional request body for title regeneration
+type RegenerateConversationTitleRequest struct {
+	ModelConfigID string `json:"modelConfigId"` // Model to use when TITLE_MODEL_CONFIG is not set
+}
+
+// RegenerateConversationTitle generates a new title for a conversation with the LLM
+func RegenerateConversationTitle(c *gin.Context) {
+	id := c.Param("id")
+
+	var req RegenerateConversationTitleRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+	}
+
+	var conv models.Conversation
+	if err := db.DB.First(&conv, "id = ?", id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
 		return
 	}
+
+	title, err := generateTitleWithLLM(c.Request.Context(), conv.ID, req.ModelConfigID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to generate title: " + err.Error()})
+		return
+	}
+
+	if err := db.DB.Model(&conv).Update("title", title).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update conversation"})
+		return
+	}
+
 	c.JSON(http.StatusOK, conv)
 }
+
+// DeleteConversation moves a conversation to the trash (soft delete).
+// With ?permanent=true the conversation, its messages and their arena votes are removed for good,
+// which also works for conversations already in the trash.
+func DeleteConversation(c *gin.Context) {
+	id := c.Param("id")
+
+	permanent, _, err := queryBool(c, "permanent")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if permanent {
+		var conv models.Conversation
+		if err := db.DB.Unscoped().First(&conv, "id = ?", id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+			return
+		}
+
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("conversation_id = ?", id).Delete(&models.Message{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("conversation_id = ?", id).Delete(&models.SharedConversation{}).Error; err != nil {
+				return err
+			}
+			if err := deleteConversationAttachments(tx, id); err != nil {
+				return err
+			}
+			if err := tx.Where("conversation_id = ?", id).Delete(&models.Image{}).Error; err != nil {
+				return err
+			}
+			// Votes point at the deleted answers, so the comparisons leave the leaderboard
+			if err := tx.Where("conversation_id = ?", id).Delete(&models.ArenaVote{}).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(&conv).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete conversation"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Conversation permanently deleted"})
+		return
+	}
+
+	var conv models.Conversation
+	if err := db.DB.First(&conv, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	if err := db.DB.Delete(&conv).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete conversation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation moved to trash"})
+}
+
+// RestoreConversation restores a soft-deleted conversation from the trash
+func RestoreConversation(c *gin.Context) {
+	id := c.Param("id")
+
+	var conv models.Conversation
+	if err := db.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&conv, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted conversation not found"})
+		return
+	}
+
+	if err := db.DB.Unscoped().Model(&conv).Update("deleted_at", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore conversation"})
+		return
+	}
+
+	conv.DeletedAt = gorm.DeletedAt{}
+	c.JSON(http.StatusOK, conv)
+}
+
+// queryBool parses a boolean query parameter, reporting whether it was set.
+// Values that are not booleans are an error rather than treated as unset.
+func queryBool(c *gin.Context, name string) (value bool, set bool, err error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return false, false, nil
+	}
+
+	value, err = strconv.ParseBool(raw)
+	if err != nil {
+		return false, false, fmt.Errorf("invalid %s parameter: expected true or false", name)
+	}
+	return value, true, nil
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"veritas-server/db"
+	"veritas-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openai/openai-go"
+)
+
+// embeddingBatchSize is the number of texts sent per embeddings request
+const embeddingBatchSize = 64
+
+// EmbeddingsRequest is an OpenAI-style embeddings request. The model is chosen
+// by ModelConfigID, or by configuration name in Model.
+type EmbeddingsRequest struct {
+	ModelConfigID string          `json:"modelConfigId"`
+	Model         string          `json:"model"`
+	Input         json.RawMessage `json:"input" binding:"required"` // A string or an array of strings
+}
+
+// EmbeddingsResponse mirrors the OpenAI embeddings response
+type EmbeddingsResponse struct {
+	Object string             `json:"object"`
+	Data   []EmbeddingsVector `json:"data"`
+	Model  string             `json:"model"`
+	Usage  EmbeddingsUsage    `json:"usage"`
+}
+
+// EmbeddingsVector is one embedding in an EmbeddingsResponse
+type EmbeddingsVector struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"`
+	Embedding []float64 `json:"embedding"`
+}
+
+// EmbeddingsUsage reports the tokens consumed by an embeddings request
+type EmbeddingsUsage struct {
+	PromptTokens int64 `json:"prompt_tokens"`
+	TotalTokens  int64 `json:"total_tokens"`
+}
+
+// CreateEmbeddings embeds text with a stored embedding model configuration, so
+// other services can use embeddings without holding the provider's API key
+func CreateEmbeddings(c *gin.Context) {
+	var req EmbeddingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	var inputs []string
+	var single string
+	if err := json.Unmarshal(req.Input, &single); err == nil {
+		inputs = []string{single}
+	} else if err := json.Unmarshal(req.Input, &inputs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "input must be a string or an array of strings"})
+		return
+	}
+	if len(inputs) == 0 || slices.Contains(inputs, "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "input must not be empty"})
+		return
+	}
+
+	var config models.ModelConfig
+	query := db.DB.Where("id = ?", req.ModelConfigID)
+	if req.ModelConfigID == "" {
+		query = db.DB.Where("name = ?", req.Model)
+	}
+	if req.ModelConfigID == "" && req.Model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "modelConfigId or model is required"})
+		return
+	}
+	if err := query.First(&config).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model configuration not found"})
+		return
+	}
+	if !config.HasCapability(models.CapabilityEmbedding) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Model configuration " + config.Name + " is not an embedding model"})
+		return
+	}
+
+	vectors, promptTokens, err := embedTexts(c.Request.Context(), &config, inputs)
+	if err != nil {
+		log.Printf("Embeddings request failed for %s: %v", config.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to create embeddings: " + err.Error()})
+		return
+	}
+
+	resp := EmbeddingsResponse{
+		Object: "list",
+		Data:   make([]EmbeddingsVector, len(vectors)),
+		Model:  config.Name,
+		Usage:  EmbeddingsUsage{PromptTokens: promptTokens, TotalTokens: promptTokens},
+	}
+	for i, vector := range vectors {
+		resp.Data[i] = EmbeddingsVector{Object: "embedding", Index: i, Embedding: vector}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// embedTexts embeds texts with the model of an embedding configuration,
+// returning one vector per text in the same order and the tokens used
+func embedTexts(ctx context.Context, config *models.ModelConfig, texts []string) ([][]float64, int64, error) {
+	if !config.HasCapability(models.CapabilityEmbedding) {
+		return nil, 0, fmt.Errorf("model configuration %s is not an embedding model", config.Name)
+	}
+
+	client, err := createLLMClientFromConfig(config, true)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var promptTokens int64
+	vectors := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += embeddingBatchSize {
+		batch := texts[start:min(start+embeddingBatchSize, len(texts))]
+		resp, err := client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+			Model: openai.EmbeddingModel(config.ModelID),
+			Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: batch},
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(resp.Data) != len(batch) {
+			return nil, 0, fmt.Errorf("embedding provider returned %d vectors for %d inputs", len(resp.Data), len(batch))
+		}
+
+		ordered := make([][]float64, len(batch))
+		for _, embedding := range resp.Data {
+			if embedding.Index < 0 || int(embedding.Index) >= len(batch) {
+				return nil, 0, fmt.Errorf("embedding provider returned invalid index %d", embedding.Index)
+			}
+			ordered[embedding.Index] = embedding.Embedding
+		}
+		vectors = append(vectors, ordered...)
+		promptTokens += resp.Usage.PromptTokens
+	}
+
+	return vectors, promptTokens, nil
+}
+
+// formatVector formats a vector as a pgvector literal, e.g. [0.1,0.2]
+func formatVector(vector []float64) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, value := range vector {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(value, 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"veritas-server/db"
+	"veritas-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RotateEncryptionKeys re-encrypts all stored API keys under the active encryption key
+func RotateEncryptionKeys(c *gin.Context) {
+	result, err := services.RotateAPIKeys(db.DB)
+	if err != nil {
+		log.Printf("Failed to rotate encryption keys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate encryption keys: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// CheckEncryptionKeys reports model configurations whose stored API keys cannot be decrypted
+func CheckEncryptionKeys(c *gin.Context) {
+	broken, err := services.CheckStoredAPIKeys(db.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check stored API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"healthy": len(broken) == 0,
+		"broken":  broken,
+	})
+}
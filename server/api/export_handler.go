@@ -0,0 +1,321 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"veritas-server/db"
+	"veritas-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFormatVersion is the version of the Veritas JSON export format
+const exportFormatVersion = 1
+
+// ConversationExport is the Veritas JSON export format. It contains every
+// message of the conversation tree; ActiveLeafID selects the displayed branch.
+type ConversationExport struct {
+	Version      int               `json:"version"`
+	ID           string            `json:"id"`
+	Title        string            `json:"title"`
+	CreatedAt    time.Time         `json:"createdAt"`
+	ExportedAt   time.Time         `json:"exportedAt"`
+	ActiveLeafID *uint             `json:"activeLeafId,omitempty"`
+	Messages     []ExportedMessage `json:"messages"`
+}
+
+// ExportedMessage is a message in the Veritas JSON export format
+type ExportedMessage struct {
+	ID        uint       `json:"id"`
+	ParentID  *uint      `json:"parentId,omitempty"`
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ModelName string     `json:"modelName,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	EditedAt  *time.Time `json:"editedAt,omitempty"`
+}
+
+// exportFormats maps the supported ?format= values to file extensions and content types
+var exportFormats = map[string]struct {
+	extension   string
+	contentType string
+}{
+	"md":   {"md", "text/markdown; charset=utf-8"},
+	"json": {"json", "application/json; charset=utf-8"},
+	"html": {"html", "text/html; charset=utf-8"},
+}
+
+// ExportConversation downloads a conversation as Markdown, JSON or HTML (?format=md|json|html).
+// Markdown and HTML contain the active branch; JSON contains every branch.
+func ExportConversation(c *gin.Context) {
+	format := c.DefaultQuery("format", "md")
+	spec, ok := exportFormats[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format: expected md, json or html"})
+		return
+	}
+
+	var conv models.Conversation
+	if err := db.DB.First(&conv, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	content, err := renderConversation(&conv, format, loadModelNames())
+	if err != nil {
+		log.Printf("Failed to export conversation %s: %v", conv.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export conversation"})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": exportFileName(&conv, spec.extension)}))
+	c.Data(http.StatusOK, spec.contentType, content)
+}
+
+// ExportAllConversations downloads every conversation as a zip archive of
+// Markdown, JSON or HTML files (?format=md|json|html)
+func ExportAllConversations(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	spec, ok := exportFormats[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format: expected md, json or html"})
+		return
+	}
+
+	var convs []models.Conversation
+	if err := db.DB.Order("created_at asc").Find(&convs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversations"})
+		return
+	}
+
+	// Render into memory first so a failure can still be reported as a JSON error
+	var archive bytes.Buffer
+	zipWriter := zip.NewWriter(&archive)
+	modelNames := loadModelNames()
+	for i := range convs {
+		content, err := renderConversation(&convs[i], format, modelNames)
+		if err != nil {
+			log.Printf("Failed to export conversation %s: %v", convs[i].ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export conversations"})
+			return
+		}
+
+		file, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:     exportFileName(&convs[i], spec.extension),
+			Method:   zip.Deflate,
+			Modified: convs[i].CreatedAt,
+		})
+		if err == nil {
+			_, err = file.Write(content)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create archive"})
+			return
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create archive"})
+		return
+	}
+
+	fileName := fmt.Sprintf("veritas-conversations-%s.zip", time.Now().Format("2006-01-02"))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	c.Data(http.StatusOK, "application/zip", archive.Bytes())
+}
+
+// renderConversation renders a conversation in the given export format
+func renderConversation(conv *models.Conversation, format string, modelNames map[string]string) ([]byte, error) {
+	if format == "json" {
+		export, err := buildConversationExport(conv, modelNames)
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(export, "", "  ")
+	}
+
+	branch, err := activeBranch(conv)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "html" {
+		return renderConversationHTML(conv, branch, modelNames)
+	}
+	return renderConversationMarkdown(conv, branch, modelNames), nil
+}
+
+// buildConversationExport converts a conversation and all of its messages to the JSON export format
+func buildConversationExport(conv *models.Conversation, modelNames map[string]string) (*ConversationExport, error) {
+	var messages []models.Message
+	if err := db.DB.
+		Where("conversation_id = ?", conv.ID).
+		Order("created_at asc").
+		Order("id asc").
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	export := &ConversationExport{
+		Version:      exportFormatVersion,
+		ID:           conv.ID,
+		Title:        conv.Title,
+		CreatedAt:    conv.CreatedAt,
+		ExportedAt:   time.Now(),
+		ActiveLeafID: conv.ActiveLeafID,
+		Messages:     make([]ExportedMessage, len(messages)),
+	}
+	for i, msg := range messages {
+		export.Messages[i] = ExportedMessage{
+			ID:        msg.ID,
+			ParentID:  msg.ParentID,
+			Role:      msg.Role,
+			Content:   msg.Content,
+			ModelName: messageModelName(&msg, modelNames),
+			CreatedAt: msg.CreatedAt,
+			EditedAt:  msg.EditedAt,
+		}
+	}
+	return export, nil
+}
+
+// renderConversationMarkdown renders a conversation branch as Markdown
+func renderConversationMarkdown(conv *models.Conversation, branch []models.Message, modelNames map[string]string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", conv.Title)
+	fmt.Fprintf(&b, "_Exported from Veritas on %s_\n", time.Now().Format("2006-01-02 15:04"))
+
+	for _, msg := range branch {
+		fmt.Fprintf(&b, "\n---\n\n### %s · %s\n\n", messageHeading(&msg, modelNames), msg.CreatedAt.Format("2006-01-02 15:04"))
+		b.WriteString(strings.TrimSpace(msg.Content))
+		b.WriteString("\n")
+	}
+	return []byte(b.String())
+}
+
+var conversationHTMLTemplate = template.Must(template.New("conversation").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #1f2937; line-height: 1.6; }
+.meta { color: #6b7280; font-size: 0.875rem; }
+.message { border-top: 1px solid #e5e7eb; padding: 1rem 0; }
+.message h2 { font-size: 1rem; margin: 0 0 0.5rem; }
+.user h2 { color: #2563eb; }
+.assistant h2 { color: #059669; }
+.content { white-space: pre-wrap; word-wrap: break-word; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">Exported from Veritas on {{.ExportedAt}}</p>
+{{range .Messages}}<div class="message {{.Role}}">
+<h2>{{.Heading}} <span class="meta">· {{.CreatedAt}}</span></h2>
+<div class="content">{{.Content}}</div>
+</div>
+{{end}}</body>
+</html>
+`))
+
+// renderConversationHTML renders a conversation branch as a standalone HTML page
+func renderConversationHTML(conv *models.Conversation, branch []models.Message, modelNames map[string]string) ([]byte, error) {
+	type htmlMessage struct {
+		Role      string
+		Heading   string
+		CreatedAt string
+		Content   string
+	}
+
+	data := struct {
+		Title      string
+		ExportedAt string
+		Messages   []htmlMessage
+	}{
+		Title:      conv.Title,
+		ExportedAt: time.Now().Format("2006-01-02 15:04"),
+	}
+	for _, msg := range branch {
+		data.Messages = append(data.Messages, htmlMessage{
+			Role:      msg.Role,
+			Heading:   messageHeading(&msg, modelNames),
+			CreatedAt: msg.CreatedAt.Format("2006-01-02 15:04"),
+			Content:   strings.TrimSpace(msg.Content),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := conversationHTMLTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// messageHeading returns "User", or "Assistant (model name)" for answers
+func messageHeading(msg *models.Message, modelNames map[string]string) string {
+	switch msg.Role {
+	case "user":
+		return "User"
+	case "assistant":
+		if name := messageModelName(msg, modelNames); name != "" {
+			return "Assistant (" + name + ")"
+		}
+		return "Assistant"
+	default:
+		return msg.Role
+	}
+}
+
+// messageModelName returns the name of the model configuration that wrote an
+// answer, as it was named at the time
+func messageModelName(msg *models.Message, modelNames map[string]string) string {
+	if msg.Role != "assistant" {
+		return ""
+	}
+	if msg.ModelName != "" {
+		return msg.ModelName
+	}
+	return modelNames[msg.ModelConfigID]
+}
+
+// loadModelNames maps model configuration IDs to their names, including deleted configurations
+func loadModelNames() map[string]string {
+	var configs []models.ModelConfig
+	if err := db.DB.Unscoped().Find(&configs).Error; err != nil {
+		log.Printf("Failed to load model configurations: %v", err)
+	}
+
+	names := make(map[string]string, len(configs))
+	for _, config := range configs {
+		names[config.ID] = config.Name
+	}
+	return names
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// exportFileName builds a file name like 2025-01-02-my-title-1a2b3c4d.md
+func exportFileName(conv *models.Conversation, extension string) string {
+	slug := strings.Trim(unsafeFileNameChars.ReplaceAllString(strings.ToLower(conv.Title), "-"), "-")
+	if runes := []rune(slug); len(runes) > 50 {
+		slug = strings.TrimRight(string(runes[:50]), "-")
+	}
+	if slug == "" {
+		slug = "conversation"
+	}
+
+	shortID := conv.ID
+	if len(shortID) > 8 {
+		shortID = shortID[:8]
+	}
+	return fmt.Sprintf("%s-%s-%s.%s", conv.CreatedAt.Format("2006-01-02"), slug, shortID, extension)
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"veritas-server/db"
+	"veritas-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/openai/openai-go"
+)
+
+const (
+	// maxImageSize limits the size of an uploaded image
+	maxImageSize = 10 << 20
+	// maxImagesPerMessage limits how many images can be sent with one message
+	maxImagesPerMessage = 10
+)
+
+// imageContentTypes are the image formats accepted by vision models
+var imageContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// UploadImage stores an image (multipart "file" field) so it can be sent with a
+// chat message through the message's imageIds
+func UploadImage(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload an image in a multipart file field"})
+		return
+	}
+	if fileHeader.Size > maxImageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Image is larger than %d MB", maxImageSize>>20)})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded image"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImageSize+1))
+	if err != nil || len(data) > maxImageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded image"})
+		return
+	}
+
+	// Trust the content, not the client's file name or header
+	contentType := http.DetectContentType(data)
+	if !imageContentTypes[contentType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported image type: expected PNG, JPEG, GIF or WebP"})
+		return
+	}
+
+	image := models.Image{
+		ID:          uuid.New().String(),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Data:        data,
+	}
+	if err := db.DB.Create(&image).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, image)
+}
+
+// GetImage serves an uploaded image
+func GetImage(c *gin.Context) {
+	var image models.Image
+	if err := db.DB.First(&image, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=86400, immutable")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, image.ContentType, image.Data)
+}
+
+// validateMessageImages checks that images can be sent to a model
+// configuration (empty for the default) within a conversation (empty for a new one)
+func validateMessageImages(modelConfigID, conversationID string, imageIDs []string) error {
+	if len(imageIDs) == 0 {
+		return nil
+	}
+	if len(imageIDs) > maxImagesPerMessage {
+		return fmt.Errorf("at most %d images can be sent with a message", maxImagesPerMessage)
+	}
+
+	var config models.ModelConfig
+	query := db.DB.Where("id = ?", modelConfigID)
+	if modelConfigID == "" {
+		query = db.DB.Where("is_default = ?", true)
+	}
+	if err := query.First(&config).Error; err != nil {
+		return errors.New("model configuration not found")
+	}
+	if !config.HasCapability(models.CapabilityVision) {
+		return fmt.Errorf("%s cannot read images: choose a model configuration with the vision capability", config.Name)
+	}
+
+	var images []models.Image
+	if err := db.DB.Select("id", "conversation_id").Where("id IN ?", imageIDs).Find(&images).Error; err != nil {
+		return err
+	}
+	found := make(map[string]bool, len(images))
+	for _, image := range images {
+		if image.ConversationID != "" && image.ConversationID != conversationID {
+			return fmt.Errorf("image %s belongs to another conversation", image.ID)
+		}
+		found[image.ID] = true
+	}
+	for _, id := range imageIDs {
+		if !found[id] {
+			return fmt.Errorf("image %s not found", id)
+		}
+	}
+
+	return nil
+}
+
+// claimImages assigns newly uploaded images to the conversation they were sent in
+func claimImages(conversationID string, imageIDs []string) error {
+	if len(imageIDs) == 0 {
+		return nil
+	}
+	return db.DB.Model(&models.Image{}).
+		Where("id IN ? AND (conversation_id = '' OR conversation_id IS NULL)", imageIDs).
+		Update("conversation_id", conversationID).Error
+}
+
+// loadImages loads the images referenced by messages, indexed by ID
+func loadImages(messages []models.Message) (map[string]models.Image, error) {
+	var ids []string
+	for _, msg := range messages {
+		ids = append(ids, msg.ImageIDs...)
+	}
+	images := make(map[string]models.Image, len(ids))
+	if len(ids) == 0 {
+		return images, nil
+	}
+
+	var rows []models.Image
+	if err := db.DB.Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, image := range rows {
+		images[image.ID] = image
+	}
+	return images, nil
+}
+
+// hasImages reports whether any of the messages has images attached
+func hasImages(messages []models.Message) bool {
+	for _, msg := range messages {
+		if len(msg.ImageIDs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// userChatMessage converts a stored user message into a chat message. Images
+// become image content parts for vision models; other models get a note that
+// images were left out, e.g. when a conversation continues with another model.
+func userChatMessage(msg *models.Message, images map[string]models.Image, vision bool) openai.ChatCompletionMessageParamUnion {
+	if len(msg.ImageIDs) == 0 {
+		return openai.UserMessage(msg.Content)
+	}
+
+	if !vision {
+		note := fmt.Sprintf("[%d image(s) omitted: this model cannot read images]", len(msg.ImageIDs))
+		return openai.UserMessage(strings.TrimSpace(msg.Content + "\n\n" + note))
+	}
+
+	var parts []openai.ChatCompletionContentPartUnionParam
+	if msg.Content != "" {
+		parts = append(parts, openai.TextContentPart(msg.Content))
+	}
+	for _, id := range msg.ImageIDs {
+		image, ok := images[id]
+		if !ok {
+			continue
+		}
+		parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+			URL: "data:" + image.ContentType + ";base64," + base64.StdEncoding.EncodeToString(image.Data),
+		}))
+	}
+	if len(parts) == 0 {
+		return openai.UserMessage(msg.Content)
+	}
+	return openai.UserMessage(parts)
+}
@@ -0,0 +1,474 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"veritas-server/db"
+	"veritas-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxImportSize limits the size of an uploaded export file
+const maxImportSize = 100 << 20
+
+// Supported values for the ?format= parameter of ImportConversations
+const (
+	importFormatChatGPT = "chatgpt"
+	importFormatVeritas = "veritas"
+)
+
+// ImportResult reports the outcome of an import
+type ImportResult struct {
+	Format           string                 `json:"format"`
+	Imported         int                    `json:"imported"`
+	Skipped          int                    `json:"skipped"`
+	ImportedMessages int                    `json:"importedMessages"`
+	SkippedMessages  int                    `json:"skippedMessages"`
+	Conversations    []ImportedConversation `json:"conversations"`
+	Errors           []ImportError          `json:"errors"`
+}
+
+// ImportedConversation identifies a conversation created by an import
+type ImportedConversation struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// ImportError explains why a conversation was skipped
+type ImportError struct {
+	Title  string `json:"title"`
+	Reason string `json:"reason"`
+}
+
+// importConversation is the format-independent form of a conversation to import
+type importConversation struct {
+	Title     string
+	CreatedAt time.Time
+	Messages  []importMessage
+	ActiveKey string // Key of the message that ends the active branch
+	Skipped   int    // Messages dropped while parsing, e.g. system or tool messages
+}
+
+// importMessage is a message to import. Keys are identifiers from the source
+// file, used to link messages to their parents.
+type importMessage struct {
+	Key       string
+	ParentKey string
+	Role      string
+	Content   string
+	ModelName string // Model that wrote an answer, if the source records it
+	CreatedAt time.Time
+}
+
+// ChatGPT export format (conversations.json)
+type chatGPTConversation struct {
+	Title       string                 `json:"title"`
+	CreateTime  float64                `json:"create_time"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+	CurrentNode string                 `json:"current_node"`
+}
+
+type chatGPTNode struct {
+	ID       string          `json:"id"`
+	Message  *chatGPTMessage `json:"message"`
+	Parent   *string         `json:"parent"`
+	Children []string        `json:"children"`
+}
+
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime *float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+	} `json:"content"`
+	Metadata struct {
+		IsVisuallyHiddenFromConversation bool `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// ImportConversations imports conversation history from an export file, sent
+// either as a multipart "file" field or as the raw request body.
+// ?format=chatgpt accepts ChatGPT's conversations.json; ?format=veritas accepts
+// the Veritas JSON export format (a single ConversationExport or an array of
+// them). Without ?format= the format is detected from the content.
+func ImportConversations(c *gin.Context) {
+	data, err := readImportPayload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		format = detectImportFormat(data)
+	}
+
+	var convs []importConversation
+	switch format {
+	case importFormatChatGPT:
+		convs, err = parseChatGPTExport(data)
+	case importFormatVeritas:
+		convs, err = parseVeritasExport(data)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown import format: expected chatgpt or veritas"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + format + " export: " + err.Error()})
+		return
+	}
+
+	result := ImportResult{
+		Format:        format,
+		Conversations: []ImportedConversation{},
+		Errors:        []ImportError{},
+	}
+	for i := range convs {
+		conv := &convs[i]
+		result.SkippedMessages += conv.Skipped
+
+		id, saved, err := saveImportedConversation(conv)
+		if err != nil {
+			result.Skipped++
+			result.SkippedMessages += len(conv.Messages)
+			result.Errors = append(result.Errors, ImportError{Title: conv.Title, Reason: err.Error()})
+			continue
+		}
+
+		result.Imported++
+		result.ImportedMessages += saved
+		result.SkippedMessages += len(conv.Messages) - saved
+		result.Conversations = append(result.Conversations, ImportedConversation{ID: id, Title: conv.Title})
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// readImportPayload reads the uploaded file, or the request body if no file was uploaded
+func readImportPayload(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var reader io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return nil, errors.New("missing file field in multipart upload")
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, errors.New("failed to read uploaded file")
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import data: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("import data is empty")
+	}
+	return data, nil
+}
+
+// detectImportFormat guesses the export format: ChatGPT conversations have a "mapping" field
+func detectImportFormat(data []byte) string {
+	var probe struct {
+		Mapping json.RawMessage `json:"mapping"`
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil || len(items) == 0 {
+			return importFormatVeritas
+		}
+		trimmed = items[0]
+	}
+
+	if err := json.Unmarshal(trimmed, &probe); err == nil && probe.Mapping != nil {
+		return importFormatChatGPT
+	}
+	return importFormatVeritas
+}
+
+// parseChatGPTExport converts ChatGPT's conversations.json. Only visible user
+// and assistant text messages are kept; the children of dropped messages are
+// attached to their closest kept ancestor.
+func parseChatGPTExport(data []byte) ([]importConversation, error) {
+	var exported []chatGPTConversation
+	if err := unmarshalOneOrMany(data, &exported); err != nil {
+		return nil, err
+	}
+
+	convs := make([]importConversation, 0, len(exported))
+	for _, source := range exported {
+		conv := importConversation{
+			Title:     source.Title,
+			CreatedAt: unixSecondsToTime(source.CreateTime),
+		}
+
+		kept := make(map[string]bool)
+		for key, node := range source.Mapping {
+			msg := node.Message
+			if msg == nil {
+				continue
+			}
+
+			role := msg.Author.Role
+			content := chatGPTMessageText(msg)
+			if (role != "user" && role != "assistant") || strings.TrimSpace(content) == "" ||
+				msg.Metadata.IsVisuallyHiddenFromConversation {
+				conv.Skipped++
+				continue
+			}
+
+			createdAt := conv.CreatedAt
+			if msg.CreateTime != nil {
+				createdAt = unixSecondsToTime(*msg.CreateTime)
+			}
+
+			kept[key] = true
+			conv.Messages = append(conv.Messages, importMessage{
+				Key:       key,
+				Role:      role,
+				Content:   content,
+				CreatedAt: createdAt,
+			})
+		}
+
+		// Link each kept message to its closest kept ancestor
+		parents := make(map[string]string, len(source.Mapping))
+		for key, node := range source.Mapping {
+			if node.Parent != nil {
+				parents[key] = *node.Parent
+			}
+		}
+		for i := range conv.Messages {
+			conv.Messages[i].ParentKey = nearestKept(parents[conv.Messages[i].Key], kept, parents)
+		}
+		conv.ActiveKey = nearestKept(source.CurrentNode, kept, parents)
+
+		convs = append(convs, conv)
+	}
+	return convs, nil
+}
+
+// nearestKept follows parent links from key to the closest kept message,
+// returning "" if there is none. The walk is bounded in case the source file
+// contains a cycle.
+func nearestKept(key string, kept map[string]bool, parents map[string]string) string {
+	for depth := 0; key != "" && depth <= len(parents); depth++ {
+		if kept[key] {
+			return key
+		}
+		key = parents[key]
+	}
+	return ""
+}
+
+// chatGPTMessageText joins the text parts of a ChatGPT message, ignoring images and other attachments
+func chatGPTMessageText(msg *chatGPTMessage) string {
+	var parts []string
+	for _, raw := range msg.Content.Parts {
+		var text string
+		if err := json.Unmarshal(raw, &text); err == nil && text != "" {
+			parts = append(parts, text)
+		}
+	}
+	if len(parts) == 0 && msg.Content.Text != "" {
+		return msg.Content.Text
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// parseVeritasExport converts the Veritas JSON export format
+func parseVeritasExport(data []byte) ([]importConversation, error) {
+	var exported []ConversationExport
+	if err := unmarshalOneOrMany(data, &exported); err != nil {
+		return nil, err
+	}
+
+	convs := make([]importConversation, 0, len(exported))
+	for _, source := range exported {
+		if source.Version > exportFormatVersion {
+			return nil, fmt.Errorf("unsupported export version %d", source.Version)
+		}
+
+		conv := importConversation{
+			Title:     source.Title,
+			CreatedAt: source.CreatedAt,
+		}
+
+		kept := make(map[string]bool)
+		parents := make(map[string]string, len(source.Messages))
+		for _, msg := range source.Messages {
+			key := strconv.FormatUint(uint64(msg.ID), 10)
+			if msg.ParentID != nil {
+				parents[key] = strconv.FormatUint(uint64(*msg.ParentID), 10)
+			}
+			if (msg.Role != "user" && msg.Role != "assistant") || msg.Content == "" {
+				conv.Skipped++
+				continue
+			}
+
+			kept[key] = true
+			conv.Messages = append(conv.Messages, importMessage{
+				Key:       key,
+				Role:      msg.Role,
+				Content:   msg.Content,
+				ModelName: msg.ModelName,
+				CreatedAt: msg.CreatedAt,
+			})
+		}
+
+		// Link each kept message to its closest kept ancestor, so skipped
+		// messages do not split the tree
+		for i := range conv.Messages {
+			conv.Messages[i].ParentKey = nearestKept(parents[conv.Messages[i].Key], kept, parents)
+		}
+		if source.ActiveLeafID != nil {
+			conv.ActiveKey = nearestKept(strconv.FormatUint(uint64(*source.ActiveLeafID), 10), kept, parents)
+		}
+
+		convs = append(convs, conv)
+	}
+	return convs, nil
+}
+
+// saveImportedConversation stores a conversation and its message tree in one
+// transaction, returning the new conversation ID and the number of messages saved
+func saveImportedConversation(source *importConversation) (string, int, error) {
+	if len(source.Messages) == 0 {
+		return "", 0, errors.New("conversation has no user or assistant messages")
+	}
+
+	title := strings.TrimSpace(source.Title)
+	if title == "" {
+		title = generateConversationTitle(source.Messages[0].Content)
+	}
+	createdAt := source.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	// Insert parents before children: order by time, then repeatedly take the
+	// messages whose parent is already saved (or missing, making them roots)
+	pending := append([]importMessage(nil), source.Messages...)
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+	keys := make(map[string]bool, len(pending))
+	for _, msg := range pending {
+		keys[msg.Key] = true
+	}
+
+	conv := models.Conversation{
+		ID:        uuid.New().String(),
+		Title:     title,
+		CreatedAt: createdAt,
+	}
+	saved := make(map[string]uint, len(pending))
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&conv).Error; err != nil {
+			return err
+		}
+
+		for len(pending) > 0 {
+			var remaining []importMessage
+			for _, msg := range pending {
+				var parentID *uint
+				if msg.ParentKey != "" && keys[msg.ParentKey] {
+					id, ok := saved[msg.ParentKey]
+					if !ok {
+						remaining = append(remaining, msg)
+						continue
+					}
+					parentID = &id
+				}
+
+				createdAt := msg.CreatedAt
+				if createdAt.IsZero() {
+					createdAt = conv.CreatedAt
+				}
+				row := models.Message{
+					ConversationID: conv.ID,
+					ParentID:       parentID,
+					Role:           msg.Role,
+					Content:        msg.Content,
+					ModelName:      msg.ModelName,
+					CreatedAt:      createdAt,
+				}
+				if err := tx.Create(&row).Error; err != nil {
+					return err
+				}
+				saved[msg.Key] = row.ID
+			}
+
+			if len(remaining) == len(pending) {
+				return errors.New("messages contain a parent cycle")
+			}
+			pending = remaining
+		}
+
+		activeLeafID, ok := saved[source.ActiveKey]
+		if !ok {
+			// Fall back to the most recent message
+			var latest models.Message
+			if err := tx.Where("conversation_id = ?", conv.ID).
+				Order("created_at desc").Order("id desc").
+				First(&latest).Error; err != nil {
+				return err
+			}
+			activeLeafID = latest.ID
+		}
+		return tx.Model(&conv).Update("active_leaf_id", activeLeafID).Error
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	return conv.ID, len(saved), nil
+}
+
+// unmarshalOneOrMany decodes either a JSON array or a single object into a slice
+func unmarshalOneOrMany[T any](data []byte, out *[]T) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return json.Unmarshal(trimmed, out)
+	}
+
+	var single T
+	if err := json.Unmarshal(trimmed, &single); err != nil {
+		return err
+	}
+	*out = []T{single}
+	return nil
+}
+
+// unixSecondsToTime converts fractional Unix seconds, as used by ChatGPT exports
+func unixSecondsToTime(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
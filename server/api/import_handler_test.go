@@ -0,0 +1,130 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestNearestKept(t *testing.T) {
+	parents := map[string]string{
+		"answer": "tool",
+		"tool":   "question",
+		"hidden": "system",
+		"cycleA": "cycleB",
+		"cycleB": "cycleA",
+	}
+	kept := map[string]bool{"question": true, "answer": true}
+
+	cases := []struct{ key, want string }{
+		{"answer", "answer"},
+		{"tool", "question"},
+		{"hidden", ""},
+		{"cycleA", ""},
+		{"missing", ""},
+		{"", ""},
+	}
+	for _, tc := range cases {
+		if got := nearestKept(tc.key, kept, parents); got != tc.want {
+			t.Errorf("nearestKept(%q) = %q, want %q", tc.key, got, tc.want)
+		}
+	}
+}
+
+// importedParents maps the content of each imported message to its parent's
+// content, and the active message to "active"
+func importedParents(t *testing.T, conv importConversation) map[string]string {
+	t.Helper()
+	content := make(map[string]string, len(conv.Messages))
+	for _, msg := range conv.Messages {
+		content[msg.Key] = msg.Content
+	}
+
+	parents := make(map[string]string, len(conv.Messages)+1)
+	for _, msg := range conv.Messages {
+		if msg.ParentKey != "" && content[msg.ParentKey] == "" {
+			t.Fatalf("message %q has parent %q, which was not imported", msg.Content, msg.ParentKey)
+		}
+		parents[msg.Content] = content[msg.ParentKey]
+	}
+	parents["active"] = content[conv.ActiveKey]
+	return parents
+}
+
+func TestParseChatGPTExport(t *testing.T) {
+	// A system root, a hidden message, a tool call between question and
+	// answer, and an edited question whose branch is current
+	data := []byte(`[{
+		"title": "Weather",
+		"create_time": 1700000000,
+		"current_node": "tool-2",
+		"mapping": {
+			"root": {"id": "root", "message": null, "parent": null},
+			"system": {"id": "system", "parent": "root", "message": {"author": {"role": "system"}, "content": {"parts": ["You are helpful"]}}},
+			"hidden": {"id": "hidden", "parent": "system", "message": {"author": {"role": "user"}, "content": {"parts": ["context"]}, "metadata": {"is_visually_hidden_from_conversation": true}}},
+			"q1": {"id": "q1", "parent": "hidden", "message": {"author": {"role": "user"}, "create_time": 1700000010, "content": {"parts": ["Weather?"]}}},
+			"tool-1": {"id": "tool-1", "parent": "q1", "message": {"author": {"role": "tool"}, "content": {"parts": ["{}"]}}},
+			"a1": {"id": "a1", "parent": "tool-1", "message": {"author": {"role": "assistant"}, "content": {"parts": ["Sunny", {"asset": "image"}]}}},
+			"q2": {"id": "q2", "parent": "hidden", "message": {"author": {"role": "user"}, "content": {"parts": ["Weather in Oslo?"]}}},
+			"a2": {"id": "a2", "parent": "q2", "message": {"author": {"role": "assistant"}, "content": {"parts": ["Rainy"]}}},
+			"tool-2": {"id": "tool-2", "parent": "a2", "message": {"author": {"role": "tool"}, "content": {"parts": ["{}"]}}}
+		}
+	}]`)
+
+	convs, err := parseChatGPTExport(data)
+	if err != nil {
+		t.Fatalf("parseChatGPTExport: %v", err)
+	}
+	if len(convs) != 1 {
+		t.Fatalf("got %d conversations, want 1", len(convs))
+	}
+	conv := convs[0]
+	if conv.Title != "Weather" || conv.Skipped != 4 {
+		t.Fatalf("title %q, skipped %d; want Weather, 4", conv.Title, conv.Skipped)
+	}
+
+	want := map[string]string{
+		"Weather?":         "",
+		"Sunny":            "Weather?",
+		"Weather in Oslo?": "",
+		"Rainy":            "Weather in Oslo?",
+		"active":           "Rainy",
+	}
+	got := importedParents(t, conv)
+	if len(got) != len(want) {
+		t.Fatalf("imported %v, want %v", got, want)
+	}
+	for content, parent := range want {
+		if got[content] != parent {
+			t.Errorf("parent of %q = %q, want %q", content, got[content], parent)
+		}
+	}
+}
+
+func TestParseVeritasExportReparentsSkippedMessages(t *testing.T) {
+	data := []byte(`{
+		"version": 1,
+		"title": "Notes",
+		"activeLeafId": 4,
+		"messages": [
+			{"id": 1, "role": "system", "content": "Be brief"},
+			{"id": 2, "parentId": 1, "role": "user", "content": "Hi"},
+			{"id": 3, "parentId": 2, "role": "assistant", "content": ""},
+			{"id": 4, "parentId": 3, "role": "tool", "content": "{}"},
+			{"id": 5, "parentId": 3, "role": "user", "content": "Anyone there?"}
+		]
+	}`)
+
+	convs, err := parseVeritasExport(data)
+	if err != nil {
+		t.Fatalf("parseVeritasExport: %v", err)
+	}
+	got := importedParents(t, convs[0])
+	want := map[string]string{"Hi": "", "Anyone there?": "Hi", "active": "Hi"}
+	if len(got) != len(want) || convs[0].Skipped != 3 {
+		t.Fatalf("imported %v with %d skipped, want %v with 3 skipped", got, convs[0].Skipped, want)
+	}
+	for content, parent := range want {
+		if got[content] != parent {
+			t.Errorf("parent of %q = %q, want %q", content, got[content], parent)
+		}
+	}
+}
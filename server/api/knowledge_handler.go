@@ -0,0 +1,445 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+	"veritas-server/db"
+	"veritas-server/models"
+	"veritas-server/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// knowledgeChunkSize and knowledgeChunkOverlap control how knowledge documents are split, in characters
+	knowledgeChunkSize    = 1000
+	knowledgeChunkOverlap = 150
+	// defaultKnowledgeResults and maxKnowledgeResults bound the number of chunks returned by a search
+	defaultKnowledgeResults = 5
+	maxKnowledgeResults     = 20
+)
+
+var (
+	// errEmbeddingFailed wraps errors from the embedding provider
+	errEmbeddingFailed = errors.New("failed to embed text")
+	// errDimensionMismatch is returned when an embedding model's output no longer matches a collection
+	errDimensionMismatch = errors.New("embedding dimension mismatch")
+)
+
+// CreateKnowledgeBaseRequest represents a request to create a knowledge collection
+type CreateKnowledgeBaseRequest struct {
+	Name                   string `json:"name" binding:"required"`
+	Description            string `json:"description"`
+	EmbeddingModelConfigID string `json:"embeddingModelConfigId" binding:"required"`
+}
+
+// AddKnowledgeDocumentRequest adds a text document to a knowledge collection
+type AddKnowledgeDocumentRequest struct {
+	Title   string `json:"title" binding:"required"`
+	Source  string `json:"source"`
+	Content string `json:"content" binding:"required"`
+}
+
+// KnowledgeSearchRequest searches knowledge collections
+type KnowledgeSearchRequest struct {
+	Query            string   `json:"query" binding:"required"`
+	KnowledgeBaseIDs []string `json:"knowledgeBaseIds"` // Empty searches every collection
+	Limit            int      `json:"limit"`
+}
+
+// KnowledgeSearchResult is a matching chunk with its provenance
+type KnowledgeSearchResult struct {
+	ChunkID        uint    `json:"chunkId"`
+	ChunkIndex     int     `json:"chunkIndex"`
+	DocumentID     string  `json:"documentId"`
+	DocumentTitle  string  `json:"documentTitle"`
+	Source         string  `json:"source"`
+	CollectionID   string  `json:"collectionId"`
+	CollectionName string  `json:"collectionName"`
+	Content        string  `json:"content"`
+	Score          float64 `json:"score"` // Cosine similarity to the query
+}
+
+// CreateKnowledgeBase creates a knowledge collection
+func CreateKnowledgeBase(c *gin.Context) {
+	var req CreateKnowledgeBaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var config models.ModelConfig
+	if err := db.DB.First(&config, "id = ?", req.EmbeddingModelConfigID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Embedding model configuration not found"})
+		return
+	}
+	if !config.HasCapability(models.CapabilityEmbedding) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Model configuration " + config.Name + " is not an embedding model"})
+		return
+	}
+
+	var count int64
+	db.DB.Model(&models.KnowledgeCollection{}).Where("name = ?", req.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A knowledge base with this name already exists"})
+		return
+	}
+
+	collection := models.KnowledgeCollection{
+		ID:                     uuid.New().String(),
+		Name:                   req.Name,
+		Description:            req.Description,
+		EmbeddingModelConfigID: config.ID,
+	}
+	if err := db.DB.Create(&collection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create knowledge base"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, collection)
+}
+
+// GetKnowledgeBases lists knowledge collections with their document counts
+func GetKnowledgeBases(c *gin.Context) {
+	var collections []models.KnowledgeCollection
+	if err := db.DB.Order("name asc").Find(&collections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch knowledge bases"})
+		return
+	}
+
+	var counts []struct {
+		CollectionID string
+		Count        int64
+	}
+	db.DB.Model(&models.KnowledgeDocument{}).
+		Select("collection_id, COUNT(*) AS count").
+		Group("collection_id").
+		Scan(&counts)
+	for _, count := range counts {
+		for i := range collections {
+			if collections[i].ID == count.CollectionID {
+				collections[i].DocumentCount = count.Count
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, collections)
+}
+
+// GetKnowledgeBase returns a knowledge collection and its documents
+func GetKnowledgeBase(c *gin.Context) {
+	var collection models.KnowledgeCollection
+	if err := db.DB.First(&collection, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Knowledge base not found"})
+		return
+	}
+
+	var documents []models.KnowledgeDocument
+	if err := db.DB.Where("collection_id = ?", collection.ID).Order("created_at asc").Find(&documents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch documents"})
+		return
+	}
+	collection.DocumentCount = int64(len(documents))
+
+	c.JSON(http.StatusOK, gin.H{"knowledgeBase": collection, "documents": documents})
+}
+
+// DeleteKnowledgeBase deletes a knowledge collection with all its documents
+func DeleteKnowledgeBase(c *gin.Context) {
+	var collection models.KnowledgeCollection
+	if err := db.DB.First(&collection, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Knowledge base not found"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&models.KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&models.KnowledgeDocument{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&collection).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete knowledge base"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Knowledge base deleted"})
+}
+
+// AddKnowledgeDocuments ingests documents into a knowledge collection. Send
+// files as multipart "file" fields (PDF, DOCX, Markdown or plain text), or a
+// single text document as JSON.
+func AddKnowledgeDocuments(c *gin.Context) {
+	var collection models.KnowledgeCollection
+	if err := db.DB.First(&collection, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Knowledge base not found"})
+		return
+	}
+
+	type pendingDocument struct {
+		title, source, text string
+	}
+	var pending []pendingDocument
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		form, err := c.MultipartForm()
+		if err != nil || len(form.File["file"]) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Upload at least one document in a multipart file field"})
+			return
+		}
+
+		for _, fileHeader := range form.File["file"] {
+			_, text, err := readAttachment("", fileHeader)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %v", fileHeader.Filename, err)})
+				return
+			}
+			name := filepath.Base(fileHeader.Filename)
+			pending = append(pending, pendingDocument{title: name, source: name, text: text})
+		}
+	} else {
+		var req AddKnowledgeDocumentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		pending = append(pending, pendingDocument{title: req.Title, source: req.Source, text: strings.TrimSpace(req.Content)})
+	}
+
+	documents := make([]models.KnowledgeDocument, 0, len(pending))
+	for _, doc := range pending {
+		document, err := ingestKnowledgeDocument(c.Request.Context(), &collection, doc.title, doc.source, doc.text)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, errEmbeddingFailed):
+				status = http.StatusBadGateway
+			case errors.Is(err, errDimensionMismatch):
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{"error": fmt.Sprintf("%s: %v", doc.title, err), "documents": documents})
+			return
+		}
+		documents = append(documents, *document)
+	}
+
+	c.JSON(http.StatusCreated, documents)
+}
+
+// ingestKnowledgeDocument chunks and embeds a document and stores it in the collection
+func ingestKnowledgeDocument(ctx context.Context, collection *models.KnowledgeCollection, title, source, text string) (*models.KnowledgeDocument, error) {
+	chunks := services.ChunkText(text, knowledgeChunkSize, knowledgeChunkOverlap)
+	if len(chunks) == 0 {
+		return nil, errors.New("document is empty")
+	}
+
+	var config models.ModelConfig
+	if err := db.DB.First(&config, "id = ?", collection.EmbeddingModelConfigID).Error; err != nil {
+		return nil, errors.New("embedding model configuration not found")
+	}
+
+	vectors, _, err := embedTexts(ctx, &config, chunks)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errEmbeddingFailed, err)
+	}
+	dimensions := len(vectors[0])
+	for _, vector := range vectors {
+		if len(vector) != dimensions || dimensions == 0 {
+			return nil, fmt.Errorf("%w: embedding model returned vectors of varying length", errEmbeddingFailed)
+		}
+	}
+	if collection.Dimensions != 0 && collection.Dimensions != dimensions {
+		return nil, fmt.Errorf("%w: the embedding model returned %d dimensions, the knowledge base uses %d",
+			errDimensionMismatch, dimensions, collection.Dimensions)
+	}
+
+	document := models.KnowledgeDocument{
+		ID:           uuid.New().String(),
+		CollectionID: collection.ID,
+		Title:        title,
+		Source:       source,
+		CharCount:    utf8.RuneCountInString(text),
+		ChunkCount:   len(chunks),
+	}
+
+	firstDocument := collection.Dimensions == 0
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if firstDocument {
+			result := tx.Model(collection).Where("dimensions = 0").Update("dimensions", dimensions)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: the knowledge base was initialized concurrently", errDimensionMismatch)
+			}
+		}
+
+		if err := tx.Create(&document).Error; err != nil {
+			return err
+		}
+
+		for i, chunk := range chunks {
+			if err := tx.Exec(
+				`INSERT INTO knowledge_chunks (document_id, collection_id, "index", content, embedding) VALUES (?, ?, ?, ?, ?::vector)`,
+				document.ID, collection.ID, i, chunk, formatVector(vectors[i]),
+			).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The first document fixes the knowledge base's dimension, which may need a new index
+	if firstDocument {
+		if err := db.EnsureVectorIndex(dimensions); err != nil {
+			log.Printf("Failed to index %d-dimensional embeddings, searches will scan every chunk: %v", dimensions, err)
+		}
+	}
+
+	return &document, nil
+}
+
+// DeleteKnowledgeDocument removes a document and its chunks from a knowledge collection
+func DeleteKnowledgeDocument(c *gin.Context) {
+	var document models.KnowledgeDocument
+	if err := db.DB.
+		Where("collection_id = ? AND id = ?", c.Param("id"), c.Param("documentId")).
+		First(&document).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", document.ID).Delete(&models.KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&document).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete document"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document deleted"})
+}
+
+// SearchKnowledgeBases runs a semantic search over knowledge collections
+func SearchKnowledgeBases(c *gin.Context) {
+	var req KnowledgeSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := searchKnowledgeBase(c.Request.Context(), req.KnowledgeBaseIDs, req.Query, req.Limit)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errEmbeddingFailed) {
+			status = http.StatusBadGateway
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// searchKnowledgeBase returns the chunks most similar to query. The query is
+// embedded once per embedding model in use by the searched collections.
+func searchKnowledgeBase(ctx context.Context, collectionIDs []string, query string, limit int) ([]KnowledgeSearchResult, error) {
+	if limit <= 0 {
+		limit = defaultKnowledgeResults
+	}
+	limit = min(limit, maxKnowledgeResults)
+
+	collections, err := searchableKnowledgeBases(collectionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byConfig := make(map[string][]models.KnowledgeCollection)
+	for _, collection := range collections {
+		byConfig[collection.EmbeddingModelConfigID] = append(byConfig[collection.EmbeddingModelConfigID], collection)
+	}
+
+	results := []KnowledgeSearchResult{}
+	for configID, group := range byConfig {
+		var config models.ModelConfig
+		if err := db.DB.First(&config, "id = ?", configID).Error; err != nil {
+			return nil, fmt.Errorf("embedding model configuration %s not found", configID)
+		}
+
+		vectors, _, err := embedTexts(ctx, &config, []string{query})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errEmbeddingFailed, err)
+		}
+
+		// Vectors of different lengths cannot be compared
+		var ids []string
+		for _, collection := range group {
+			if collection.Dimensions == len(vectors[0]) {
+				ids = append(ids, collection.ID)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		var matches []KnowledgeSearchResult
+		vector := formatVector(vectors[0])
+		distance, sameDimension := db.VectorDistance("c.embedding", len(vectors[0]))
+		if err := db.DB.Raw(`
+			SELECT c.id AS chunk_id, c."index" AS chunk_index, c.content,
+				d.id AS document_id, d.title AS document_title, d.source,
+				k.id AS collection_id, k.name AS collection_name,
+				1 - (`+distance+`) AS score
+			FROM knowledge_chunks c
+			JOIN knowledge_documents d ON d.id = c.document_id
+			JOIN knowledge_collections k ON k.id = c.collection_id
+			WHERE c.collection_id IN ? AND `+sameDimension+`
+			ORDER BY `+distance+`
+			LIMIT ?`, vector, ids, vector, limit).
+			Scan(&matches).Error; err != nil {
+			return nil, err
+		}
+		results = append(results, matches...)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// searchableKnowledgeBases loads the given collections, or all of them if ids
+// is empty, skipping collections that have no documents yet
+func searchableKnowledgeBases(ids []string) ([]models.KnowledgeCollection, error) {
+	query := db.DB.Where("dimensions > 0").Order("name asc")
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+
+	var collections []models.KnowledgeCollection
+	if err := query.Find(&collections).Error; err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
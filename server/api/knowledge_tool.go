@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"veritas-server/models"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	// knowledgeToolName is the name of the knowledge base search tool offered to the model
+	knowledgeToolName = "search_knowledge_base"
+	// maxToolRounds limits how many times the model may call tools before it must answer
+	maxToolRounds = 3
+)
+
+// knowledgeToolResult is a search result as returned to the model
+type knowledgeToolResult struct {
+	Ref           int     `json:"ref"`
+	DocumentTitle string  `json:"documentTitle"`
+	Source        string  `json:"source,omitempty"`
+	KnowledgeBase string  `json:"knowledgeBase"`
+	Chunk         int     `json:"chunk"`
+	Score         float64 `json:"score"`
+	Content       string  `json:"content"`
+}
+
+// knowledgeTool describes the search_knowledge_base tool, listing the
+// collections the model can search
+func knowledgeTool(collections []models.KnowledgeCollection) openai.ChatCompletionToolParam {
+	var description strings.Builder
+	description.WriteString("Search the organization's internal knowledge base and return the most relevant passages with their source documents. ")
+	description.WriteString("Use it for questions about internal documentation, and cite the passages you use by document title and source. Available knowledge bases:")
+	names := make([]string, len(collections))
+	for i, collection := range collections {
+		names[i] = collection.Name
+		fmt.Fprintf(&description, "\n- %s", collection.Name)
+		if collection.Description != "" {
+			fmt.Fprintf(&description, ": %s", collection.Description)
+		}
+	}
+
+	return openai.ChatCompletionToolParam{
+		Function: shared.FunctionDefinitionParam{
+			Name:        knowledgeToolName,
+			Description: openai.String(description.String()),
+			Parameters: shared.FunctionParameters{
+				"type": "object",
+				"properties": map[string]any{
+					"query": map[string]any{
+						"type":        "string",
+						"description": "What to search for, phrased as a question or keywords",
+					},
+					"knowledge_base": map[string]any{
+						"type":        "string",
+						"description": "Name of a knowledge base to restrict the search to; omit to search all of them",
+						"enum":        names,
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
+	}
+}
+
+// runKnowledgeTool executes a search_knowledge_base call and returns the
+// result for the model. Failures are reported to the model as text so it can
+// answer without the knowledge base.
+func runKnowledgeTool(ctx context.Context, call openai.ChatCompletionMessageToolCall, collections []models.KnowledgeCollection) string {
+	if call.Function.Name != knowledgeToolName {
+		return fmt.Sprintf("Error: unknown tool %q", call.Function.Name)
+	}
+
+	var args struct {
+		Query         string `json:"query"`
+		KnowledgeBase string `json:"knowledge_base"`
+	}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil || strings.TrimSpace(args.Query) == "" {
+		return "Error: the query argument is required"
+	}
+
+	ids := make([]string, 0, len(collections))
+	for _, collection := range collections {
+		if args.KnowledgeBase == "" || strings.EqualFold(collection.Name, args.KnowledgeBase) {
+			ids = append(ids, collection.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return fmt.Sprintf("Error: unknown knowledge base %q", args.KnowledgeBase)
+	}
+
+	results, err := searchKnowledgeBase(ctx, ids, args.Query, defaultKnowledgeResults)
+	if err != nil {
+		log.Printf("Knowledge base search failed: %v", err)
+		return "Error: the knowledge base search failed"
+	}
+	if len(results) == 0 {
+		return "No matching passages found."
+	}
+
+	passages := make([]knowledgeToolResult, len(results))
+	for i, result := range results {
+		passages[i] = knowledgeToolResult{
+			Ref:           i + 1,
+			DocumentTitle: result.DocumentTitle,
+			Source:        result.Source,
+			KnowledgeBase: result.CollectionName,
+			Chunk:         result.ChunkIndex + 1,
+			Score:         result.Score,
+			Content:       result.Content,
+		}
+	}
+
+	output, err := json.Marshal(passages)
+	if err != nil {
+		return "Error: failed to encode search results"
+	}
+	return string(output)
+}
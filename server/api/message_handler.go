@@ -0,0 +1,238 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"veritas-server/db"
+	"veritas-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EditMessageRequest represents the request body for editing a user message
+type EditMessageRequest struct {
+	Content       string `json:"content" binding:"required"`
+	ModelConfigID string `json:"modelConfigId"` // Optional, defaults to the model used for the original message
+}
+
+// RegenerateMessageRequest represents the request body for regenerating an answer
+type RegenerateMessageRequest struct {
+	ModelConfigID string `json:"modelConfigId"` // Optional, defaults to the model of the message being regenerated
+}
+
+// SwitchBranchRequest represents the request body for selecting a conversation branch
+type SwitchBranchRequest struct {
+	MessageID uint `json:"messageId" binding:"required"`
+}
+
+// EditMessage edits a past user message and regenerates the assistant reply.
+// The edited message is stored as a new sibling of the original, so the
+// original question and its later turns stay available as another branch.
+func EditMessage(c *gin.Context) {
+	conversationID := c.Param("id")
+	messageID := c.Param("msgId")
+
+	var req EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content cannot be empty"})
+		return
+	}
+
+	var original models.Message
+	if err := db.DB.First(&original, "id = ? AND conversation_id = ?", messageID, conversationID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+	if original.Role != "user" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only user messages can be edited"})
+		return
+	}
+
+	inherited := req.ModelConfigID == ""
+	if inherited {
+		req.ModelConfigID = activeModelConfigID(original.ModelConfigID)
+	} else {
+		var config models.ModelConfig
+		if err := db.DB.First(&config, "id = ?", req.ModelConfigID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid model configuration"})
+			return
+		}
+	}
+	if err := validateMessageImages(req.ModelConfigID, conversationID, original.ImageIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	edited := &models.Message{
+		ConversationID: conversationID,
+		ParentID:       original.ParentID,
+		Role:           "user",
+		Content:        req.Content,
+		ModelConfigID:  req.ModelConfigID,
+		ImageIDs:       original.ImageIDs, // Editing changes the text, the images stay
+		CreatedAt:      now,
+		EditedAt:       &now,
+	}
+	if err := appendMessage(edited); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to edit message"})
+		return
+	}
+
+	chatReq := ChatRequest{
+		ModelConfigID:  req.ModelConfigID,
+		Message:        req.Content,
+		ConversationID: conversationID,
+		AllowFallback:  inherited,
+	}
+	resp := getLLMResponse(c.Request.Context(), chatReq, edited)
+
+	if _, err := saveAssistantMessage(conversationID, resp, edited.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save response"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ChatResponse{
+		Response:       resp.Content,
+		ConversationID: conversationID,
+	})
+}
+
+// RegenerateMessage generates a new answer, optionally with a different model
+// configuration, and stores it as a variant next to the existing answers.
+// msgId may be an assistant message (its question is answered again) or a user
+// message (answered anew). Returns the new answer with all its variants.
+func RegenerateMessage(c *gin.Context) {
+	conversationID := c.Param("id")
+	messageID := c.Param("msgId")
+
+	var req RegenerateMessageRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+	}
+
+	var msg models.Message
+	if err := db.DB.First(&msg, "id = ? AND conversation_id = ?", messageID, conversationID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	question := msg
+	if msg.Role == "assistant" {
+		if msg.ParentID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Message has no question to answer"})
+			return
+		}
+		// Load into a fresh struct: gorm would add the answer's primary key as a condition
+		question = models.Message{}
+		if err := db.DB.First(&question, "id = ?", *msg.ParentID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+			return
+		}
+	}
+	if question.Role != "user" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only answers to user messages can be regenerated"})
+		return
+	}
+
+	inherited := req.ModelConfigID == ""
+	if inherited {
+		req.ModelConfigID = activeModelConfigID(msg.ModelConfigID)
+	} else {
+		var config models.ModelConfig
+		if err := db.DB.First(&config, "id = ?", req.ModelConfigID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid model configuration"})
+			return
+		}
+	}
+	if err := validateMessageImages(req.ModelConfigID, conversationID, question.ImageIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chatReq := ChatRequest{
+		ModelConfigID:  req.ModelConfigID,
+		Message:        question.Content,
+		ConversationID: conversationID,
+		AllowFallback:  inherited,
+	}
+	resp := getLLMResponse(c.Request.Context(), chatReq, &question)
+
+	answer, err := saveAssistantMessage(conversationID, resp, question.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save response"})
+		return
+	}
+
+	answers := []models.Message{*answer}
+	if err := addSiblings(conversationID, answers); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load messages"})
+		return
+	}
+	c.JSON(http.StatusOK, answers[0])
+}
+
+// SwitchBranch selects the branch containing a message. If the message has
+// replies, the branch continues down to its most recent descendant.
+func SwitchBranch(c *gin.Context) {
+	conversationID := c.Param("id")
+
+	var req SwitchBranchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	var conv models.Conversation
+	if err := db.DB.First(&conv, "id = ?", conversationID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	leafID, err := latestLeaf(conversationID, req.MessageID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load messages"})
+		return
+	}
+	if leafID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	if err := db.DB.Model(&conv).Update("active_leaf_id", leafID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to switch branch"})
+		return
+	}
+
+	branch, err := branchMessages(conversationID, leafID)
+	if err == nil {
+		err = addSiblings(conversationID, branch)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load messages"})
+		return
+	}
+	conv.Messages = branch
+	c.JSON(http.StatusOK, ConversationResponse{
+		Conversation:  conv,
+		TotalMessages: int64(len(branch)),
+	})
+}
+
+// activeModelConfigID returns the ID of a message's model configuration, or an
+// empty ID (the default configuration) if it has been deleted since
+func activeModelConfigID(id string) string {
+	var config models.ModelConfig
+	if id == "" || db.DB.Select("id").First(&config, "id = ?", id).Error != nil {
+		return ""
+	}
+	return id
+}
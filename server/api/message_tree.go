@@ -0,0 +1,208 @@
+package api
+
+import (
+	"errors"
+	"slices"
+	"veritas-server/db"
+	"veritas-server/models"
+
+	"gorm.io/gorm"
+)
+
+// branchCTE walks up the parent links from a leaf (first parameter) of a
+// conversation (second parameter). Each row's path lists the IDs from the leaf
+// up to the row, which orders the branch and stops at cycles in corrupted data.
+const branchCTE = `WITH RECURSIVE branch (id, parent_id, path) AS (
+	SELECT id, parent_id, ARRAY[id] FROM messages WHERE id = ? AND conversation_id = ?
+	UNION ALL
+	SELECT m.id, m.parent_id, b.path || m.id FROM messages m JOIN branch b ON m.id = b.parent_id
+	WHERE NOT m.id = ANY(b.path)
+)`
+
+// latestLeafCTE walks down from a message (first parameter) of a
+// conversation (second parameter), following the most recent reply
+const latestLeafCTE = `WITH RECURSIVE descent (id, path) AS (
+	SELECT id, ARRAY[id] FROM messages WHERE id = ? AND conversation_id = ?
+	UNION ALL
+	SELECT child.id, d.path || child.id FROM descent d
+	CROSS JOIN LATERAL (
+		SELECT id FROM messages WHERE parent_id = d.id ORDER BY created_at DESC, id DESC LIMIT 1
+	) child
+	WHERE NOT child.id = ANY(d.path)
+)`
+
+// branchQuery returns a query for the messages on the branch ending with leafID.
+// Only the branch is read, so it can be paginated with fetchPage.
+func branchQuery(conversationID string, leafID uint) *gorm.DB {
+	return db.DB.Model(&models.Message{}).
+		Where("id IN (?)", db.DB.Raw(branchCTE+" SELECT id FROM branch", leafID, conversationID))
+}
+
+// branchMessages returns the branch from the root down to leafID in chronological order
+func branchMessages(conversationID string, leafID uint) ([]models.Message, error) {
+	var ids []uint
+	if err := db.DB.Raw(branchCTE+" SELECT id FROM branch ORDER BY cardinality(path) DESC", leafID, conversationID).
+		Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []models.Message{}, nil
+	}
+
+	var messages []models.Message
+	if err := db.DB.Where("id IN ?", ids).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	position := make(map[uint]int, len(ids))
+	for i, id := range ids {
+		position[id] = i
+	}
+	slices.SortFunc(messages, func(a, b models.Message) int {
+		return position[a.ID] - position[b.ID]
+	})
+	return messages, nil
+}
+
+// addSiblings fills in the sibling IDs of messages of a conversation. Assistant
+// messages with alternative answers also get all of them as variants.
+func addSiblings(conversationID string, messages []models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	var parentIDs []uint
+	hasRoot := false
+	for _, msg := range messages {
+		if msg.ParentID == nil {
+			hasRoot = true
+		} else {
+			parentIDs = append(parentIDs, *msg.ParentID)
+		}
+	}
+
+	query := db.DB.Where("conversation_id = ?", conversationID)
+	switch {
+	case hasRoot && len(parentIDs) > 0:
+		query = query.Where("parent_id IN ? OR parent_id IS NULL", parentIDs)
+	case hasRoot:
+		query = query.Where("parent_id IS NULL")
+	default:
+		query = query.Where("parent_id IN ?", parentIDs)
+	}
+	var siblings []models.Message
+	if err := query.Order("created_at asc").Order("id asc").Find(&siblings).Error; err != nil {
+		return err
+	}
+
+	children := make(map[uint][]models.Message) // Key 0 holds root messages
+	for _, sibling := range siblings {
+		children[parentKey(&sibling)] = append(children[parentKey(&sibling)], sibling)
+	}
+	for i := range messages {
+		msg := &messages[i]
+		group := children[parentKey(msg)]
+		msg.SiblingIDs = make([]uint, len(group))
+		for j, sibling := range group {
+			msg.SiblingIDs[j] = sibling.ID
+		}
+		if msg.Role == "assistant" && len(group) > 1 {
+			msg.Variants = group
+		}
+	}
+	return nil
+}
+
+// parentKey returns the key of a message's parent in addSiblings' children
+func parentKey(msg *models.Message) uint {
+	if msg.ParentID == nil {
+		return 0
+	}
+	return *msg.ParentID
+}
+
+// latestLeaf follows the most recent reply from messageID down to a leaf
+func latestLeaf(conversationID string, messageID uint) (uint, error) {
+	var leafID uint
+	err := db.DB.Raw(latestLeafCTE+" SELECT id FROM descent ORDER BY cardinality(path) DESC LIMIT 1", messageID, conversationID).
+		Scan(&leafID).Error
+	return leafID, err
+}
+
+// activeLeaf returns the conversation's active leaf, falling back to the most
+// recent message if none is set. Returns 0 for an empty conversation.
+func activeLeaf(conv *models.Conversation) (uint, error) {
+	var leaf models.Message
+	query := db.DB.Select("id").Where("conversation_id = ?", conv.ID)
+	if conv.ActiveLeafID != nil {
+		err := query.Session(&gorm.Session{}).Where("id = ?", *conv.ActiveLeafID).Take(&leaf).Error
+		if err == nil {
+			return leaf.ID, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+	}
+
+	err := query.Order("created_at desc").Order("id desc").Take(&leaf).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return leaf.ID, err
+}
+
+// activeBranch returns the messages of a conversation's selected branch in
+// chronological order, with their sibling IDs and variants
+func activeBranch(conv *models.Conversation) ([]models.Message, error) {
+	leafID, err := activeLeaf(conv)
+	if err != nil || leafID == 0 {
+		return []models.Message{}, err
+	}
+	branch, err := branchMessages(conv.ID, leafID)
+	if err != nil {
+		return nil, err
+	}
+	return branch, addSiblings(conv.ID, branch)
+}
+
+// activeBranchPage returns one page of a conversation's selected branch in
+// chronological order, with the sibling IDs and variants of its messages
+func activeBranchPage(conv *models.Conversation, req pageRequest) (Page[models.Message], error) {
+	leafID, err := activeLeaf(conv)
+	if err != nil || leafID == 0 {
+		return Page[models.Message]{Items: []models.Message{}}, err
+	}
+	page, err := fetchPage(branchQuery(conv.ID, leafID), req, false, messageCursor)
+	if err != nil {
+		return page, err
+	}
+	return page, addSiblings(conv.ID, page.Items)
+}
+
+// appendMessage saves a message and makes it the conversation's active leaf.
+// The name of its model configuration is stored with it, so the message keeps
+// showing which model was used after the configuration is renamed or deleted.
+func appendMessage(msg *models.Message) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if msg.ModelConfigID != "" && msg.ModelName == "" {
+			var config models.ModelConfig
+			if err := tx.Unscoped().Select("name").First(&config, "id = ?", msg.ModelConfigID).Error; err == nil {
+				msg.ModelName = config.Name
+			}
+		}
+
+		if err := tx.Create(msg).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.Conversation{}).
+			Where("id = ?", msg.ConversationID).
+			Update("active_leaf_id", msg.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("conversation not found")
+		}
+		return nil
+	})
+}
@@ -1,8 +1,12 @@
 package api
 
 import (
-	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 	"veritas-server/db"
 	"veritas-server/models"
@@ -10,7 +14,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/openai/openai-go"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
 )
 
 // ModelConfigRequest represents the request body for creating/updating model configs
@@ -21,6 +26,12 @@ type ModelConfigRequest struct {
 	ModelID   string `json:"modelId" binding:"required"`
 	APIKey    string `json:"apiKey"` // Optional for local models like Ollama
 	IsDefault bool   `json:"isDefault"`
+	// Capabilities defaults to ["chat"] on create, with "vision" for models the
+	// catalog lists as reading images, and is left unchanged on update when omitted
+	Capabilities []string `json:"capabilities"`
+	// CatalogOverrides replaces model catalog values; it is left unchanged on
+	// update when omitted and cleared by an empty object
+	CatalogOverrides *models.ModelCatalogOverride `json:"catalogOverrides"`
 }
 
 // CreateModelConfig creates a new model configuration
@@ -31,6 +42,24 @@ func CreateModelConfig(c *gin.Context) {
 		return
 	}
 
+	capabilities := services.DefaultCapabilities(req.ModelID)
+	if req.Capabilities != nil {
+		var err error
+		if capabilities, err = normalizeCapabilities(req.Capabilities); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.IsDefault && !slices.Contains(capabilities, models.CapabilityChat) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The default configuration must have the chat capability"})
+		return
+	}
+	overrides, err := normalizeCatalogOverrides(req.CatalogOverrides)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Encrypt API key if provided
 	var encryptedKey string
 	if req.APIKey != "" {
@@ -51,21 +80,21 @@ func CreateModelConfig(c *gin.Context) {
 	}
 
 	config := models.ModelConfig{
-		ID:        uuid.New().String(),
-		Name:      req.Name,
-		Provider:  req.Provider,
-		BaseURL:   req.BaseURL,
-		ModelID:   req.ModelID,
-		APIKey:    encryptedKey,
-		IsDefault: req.IsDefault,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:               uuid.New().String(),
+		Name:             req.Name,
+		Provider:         req.Provider,
+		BaseURL:          req.BaseURL,
+		ModelID:          req.ModelID,
+		APIKey:           encryptedKey,
+		IsDefault:        req.IsDefault,
+		Capabilities:     capabilities,
+		CatalogOverrides: overrides,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
 	}
 
 	if err := db.DB.Create(&config).Error; err != nil {
-		// Check for duplicate name
-		if err.Error() == "UNIQUE constraint failed: model_configs.name" ||
-			err.Error() == "duplicate key value violates unique constraint \"model_configs_name_key\"" {
+		if isUniqueViolation(err) {
 			c.JSON(http.StatusConflict, gin.H{"error": "A configuration with this name already exists"})
 			return
 		}
@@ -121,6 +150,27 @@ func UpdateModelConfig(c *gin.Context) {
 		return
 	}
 
+	capabilities := config.CapabilityList()
+	if req.Capabilities != nil {
+		var err error
+		if capabilities, err = normalizeCapabilities(req.Capabilities); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.IsDefault && !slices.Contains(capabilities, models.CapabilityChat) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The default configuration must have the chat capability"})
+		return
+	}
+	overrides := config.CatalogOverrides
+	if req.CatalogOverrides != nil {
+		var err error
+		if overrides, err = normalizeCatalogOverrides(req.CatalogOverrides); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// Encrypt new API key if provided
 	var encryptedKey string
 	if req.APIKey != "" {
@@ -150,9 +200,15 @@ func UpdateModelConfig(c *gin.Context) {
 	config.ModelID = req.ModelID
 	config.APIKey = encryptedKey
 	config.IsDefault = req.IsDefault
+	config.Capabilities = capabilities
+	config.CatalogOverrides = overrides
 	config.UpdatedAt = time.Now()
 
 	if err := db.DB.Save(&config).Error; err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A configuration with this name already exists"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update configuration"})
 		return
 	}
@@ -160,7 +216,11 @@ func UpdateModelConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, config.ToResponse())
 }
 
-// DeleteModelConfig deletes a model configuration
+// DeleteModelConfig soft deletes a model configuration: it is hidden from
+// selection while messages, votes and usage records keep referring to it.
+// Optional ?reassignTo= moves its conversation messages to another chat
+// configuration, so editing and regenerating them uses that one. Arena answers
+// stay with the deleted configuration to keep the leaderboard accurate.
 func DeleteModelConfig(c *gin.Context) {
 	id := c.Param("id")
 
@@ -170,24 +230,103 @@ func DeleteModelConfig(c *gin.Context) {
 		return
 	}
 
-	// Check if config is referenced by any messages
-	var messageCount int64
-	if err := db.DB.Model(&models.Message{}).Where("model_config_id = ?", id).Count(&messageCount).Error; err != nil {
+	// Knowledge bases cannot be searched without the model that embedded them
+	var collectionCount int64
+	if err := db.DB.Model(&models.KnowledgeCollection{}).Where("embedding_model_config_id = ?", id).Count(&collectionCount).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check configuration usage"})
 		return
 	}
-
-	if messageCount > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete configuration that is referenced by messages"})
+	if collectionCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot delete configuration that is used by knowledge bases"})
 		return
 	}
 
-	if err := db.DB.Delete(&config).Error; err != nil {
+	reassignTo := c.Query("reassignTo")
+	if reassignTo != "" {
+		if reassignTo == id {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot reassign messages to the configuration being deleted"})
+			return
+		}
+		var target models.ModelConfig
+		if err := db.DB.First(&target, "id = ?", reassignTo).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reassignment configuration not found"})
+			return
+		}
+		if !target.HasCapability(models.CapabilityChat) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Messages can only be reassigned to a chat model"})
+			return
+		}
+	}
+
+	var reassigned int64
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if reassignTo != "" {
+			// Messages keep their model name, so they still show which model answered
+			result := tx.Model(&models.Message{}).
+				Where("model_config_id = ? AND (comparison_id IS NULL OR comparison_id = '')", id).
+				Update("model_config_id", reassignTo)
+			if result.Error != nil {
+				return result.Error
+			}
+			reassigned = result.RowsAffected
+		}
+
+		// The key is no longer needed, and a deleted configuration is never the default
+		if err := tx.Model(&config).Updates(map[string]any{"api_key": "", "is_default": false}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&config).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete configuration"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Configuration deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Configuration deleted successfully", "reassignedMessages": reassigned})
+}
+
+// normalizeCatalogOverrides rejects negative limits and prices and returns nil
+// when no value is overridden
+func normalizeCatalogOverrides(overrides *models.ModelCatalogOverride) (*models.ModelCatalogOverride, error) {
+	if overrides == nil || *overrides == (models.ModelCatalogOverride{}) {
+		return nil, nil
+	}
+	if (overrides.ContextWindow != nil && *overrides.ContextWindow <= 0) ||
+		(overrides.MaxOutputTokens != nil && *overrides.MaxOutputTokens <= 0) {
+		return nil, errors.New("catalog override token limits must be positive")
+	}
+	if (overrides.InputPricePerMillion != nil && *overrides.InputPricePerMillion < 0) ||
+		(overrides.OutputPricePerMillion != nil && *overrides.OutputPricePerMillion < 0) {
+		return nil, errors.New("catalog override prices must not be negative")
+	}
+	return overrides, nil
+}
+
+// normalizeCapabilities validates capabilities and returns them deduplicated in canonical order
+func normalizeCapabilities(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, errors.New("at least one capability is required")
+	}
+
+	seen := make(map[string]bool)
+	for _, capability := range requested {
+		capability = strings.ToLower(strings.TrimSpace(capability))
+		if !slices.Contains(models.Capabilities, capability) {
+			return nil, fmt.Errorf("unknown capability %q: expected one of %s", capability, strings.Join(models.Capabilities, ", "))
+		}
+		seen[capability] = true
+	}
+	if seen[models.CapabilityVision] && !seen[models.CapabilityChat] {
+		return nil, errors.New("the vision capability requires the chat capability")
+	}
+
+	var capabilities []string
+	for _, capability := range models.Capabilities {
+		if seen[capability] {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	return capabilities, nil
 }
 
 // TestModelConfigRequest represents the request body for testing a model config
@@ -195,14 +334,10 @@ type TestModelConfigRequest struct {
 	BaseURL string `json:"baseUrl"`
 	ModelID string `json:"modelId" binding:"required"`
 	APIKey  string `json:"apiKey"` // Optional for local models like Ollama
-}
-
-// TestModelConfigResponse represents the response for testing a model config
-type TestModelConfigResponse struct {
-	Success      bool              `json:"success"`
-	Message      string            `json:"message"`
-	Details      map[string]string `json:"details,omitempty"`
-	ErrorDetails string            `json:"errorDetails,omitempty"`
+	// Capabilities selects the checks: a chat completion and feature probes for
+	// chat models, an embedding for embedding models, otherwise a model lookup.
+	// Defaults to chat.
+	Capabilities []string `json:"capabilities"`
 }
 
 // TestModelConfig tests a model configuration without saving
@@ -213,113 +348,59 @@ func TestModelConfig(c *gin.Context) {
 		return
 	}
 
-	// Create a temporary model config for testing
-	tempConfig := &models.ModelConfig{
-		BaseURL: req.BaseURL,
-		ModelID: req.ModelID,
-		APIKey:  req.APIKey, // Use plaintext for testing (not encrypted)
-	}
-
-	// Test the connection with timeout
-	startTime := time.Now()
-	client, err := createLLMClientFromConfig(tempConfig, false) // false = don't decrypt
-	if err != nil {
-		c.JSON(http.StatusOK, TestModelConfigResponse{
-			Success:      false,
-			Message:      "Failed to create LLM client",
-			ErrorDetails: err.Error(),
-		})
-		return
-	}
-
-	// Try a simple completion to test the connection
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
-	defer cancel()
-
-	_, err = client.Chat.Completions.New(
-		ctx,
-		openai.ChatCompletionNewParams{
-			Model: openai.ChatModel(req.ModelID), //nolint:unconvert
-			Messages: []openai.ChatCompletionMessageParamUnion{
-				openai.UserMessage("Hello"),
-			},
-			MaxTokens: openai.Int(10),
-		},
-	)
-
-	responseTime := time.Since(startTime)
-
-	if err != nil {
-		// Determine error type
-		errorMsg := err.Error()
-		statusCode := http.StatusOK // Still return 200, but with success: false
-
-		if ctx.Err() != nil {
-			c.JSON(statusCode, TestModelConfigResponse{
-				Success:      false,
-				Message:      "Connection timeout",
-				ErrorDetails: "Request timed out after 30 seconds",
-			})
+	capabilities := []string{models.CapabilityChat}
+	if req.Capabilities != nil {
+		var err error
+		if capabilities, err = normalizeCapabilities(req.Capabilities); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+	}
 
-		// Check for common error patterns
-		if contains(errorMsg, "401") || contains(errorMsg, "unauthorized") || contains(errorMsg, "invalid_api_key") {
-			c.JSON(statusCode, TestModelConfigResponse{
-				Success:      false,
-				Message:      "Authentication failed",
-				ErrorDetails: "Invalid API key",
-			})
-			return
-		}
+	// Create a temporary model config for testing
+	tempConfig := &models.ModelConfig{
+		BaseURL:      req.BaseURL,
+		ModelID:      req.ModelID,
+		APIKey:       req.APIKey, // Use plaintext for testing (not encrypted)
+		Capabilities: capabilities,
+	}
 
-		if contains(errorMsg, "404") || contains(errorMsg, "model_not_found") {
-			c.JSON(statusCode, TestModelConfigResponse{
-				Success:      false,
-				Message:      "Invalid model ID",
-				ErrorDetails: "The specified model does not exist",
-			})
-			return
-		}
+	// Still return 200 when the test fails, with success: false
+	c.JSON(http.StatusOK, runModelDiagnostics(c.Request.Context(), tempConfig, false)) // false = don't decrypt
+}
 
-		if contains(errorMsg, "429") || contains(errorMsg, "rate_limit") {
-			c.JSON(statusCode, TestModelConfigResponse{
-				Success:      false,
-				Message:      "Rate limit exceeded",
-				ErrorDetails: "Too many requests, please try again later",
-			})
-			return
-		}
+// TestSavedModelConfig runs the diagnostics on a saved configuration with its
+// stored API key and records the result on the configuration
+func TestSavedModelConfig(c *gin.Context) {
+	id := c.Param("id")
 
-		c.JSON(statusCode, TestModelConfigResponse{
-			Success:      false,
-			Message:      "Connection failed",
-			ErrorDetails: errorMsg,
-		})
+	var config models.ModelConfig
+	if err := db.DB.First(&config, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Configuration not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, TestModelConfigResponse{
-		Success: true,
-		Message: "Connection successful",
-		Details: map[string]string{
-			"responseTime":   responseTime.String(),
-			"modelAvailable": "true",
-		},
-	})
-}
+	result := runModelDiagnostics(c.Request.Context(), &config, true) // true = decrypt API key
 
-// contains checks if a string contains a substring (case-insensitive)
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
-		(len(s) > 0 && len(substr) > 0 && containsHelper(s, substr)))
+	message := result.Message
+	if result.ErrorDetails != "" {
+		message += ": " + result.ErrorDetails
+	}
+	// A test is not an edit, so UpdatedAt is left alone
+	if err := db.DB.Model(&config).UpdateColumns(map[string]any{
+		"last_tested_at":    time.Now(),
+		"last_test_success": result.Success,
+		"last_test_message": message,
+	}).Error; err != nil {
+		log.Printf("Failed to record test result for model config %s: %v", config.ID, err)
+	}
+
+	c.JSON(http.StatusOK, result)
 }
 
-func containsHelper(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
+// isUniqueViolation reports whether err is a unique constraint violation,
+// which for model configurations means the name is taken
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" // unique_violation
 }
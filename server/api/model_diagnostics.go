@@ -0,0 +1,305 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"veritas-server/models"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// Error classes reported by model diagnostics
+const (
+	errorClassAuth          = "auth"
+	errorClassModelNotFound = "model_not_found"
+	errorClassRateLimit     = "rate_limit"
+	errorClassBadRequest    = "bad_request"
+	errorClassServerError   = "server_error"
+	errorClassTimeout       = "timeout"
+	errorClassConnection    = "connection"
+	errorClassUnsupported   = "unsupported"
+	// errorClassConfiguration means no client could be created, e.g. because the API key cannot be decrypted
+	errorClassConfiguration = "configuration"
+)
+
+// Diagnostic checks
+const (
+	checkChat        = "chat"
+	checkEmbedding   = "embedding"
+	checkModelLookup = "modelLookup"
+	checkStreaming   = "streaming"
+	checkToolCalling = "toolCalling"
+	checkJSONMode    = "jsonMode"
+)
+
+// diagnosticTimeout bounds each diagnostic check
+const diagnosticTimeout = 30 * time.Second
+
+// DiagnosticCheck is the outcome of one diagnostic check
+type DiagnosticCheck struct {
+	Name       string `json:"name"`
+	Supported  bool   `json:"supported"`
+	LatencyMs  int64  `json:"latencyMs"`
+	ErrorClass string `json:"errorClass,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// TestModelConfigResponse represents the response for testing a model config
+type TestModelConfigResponse struct {
+	Success      bool              `json:"success"`
+	Message      string            `json:"message"`
+	Details      map[string]string `json:"details,omitempty"`
+	ErrorClass   string            `json:"errorClass,omitempty"`
+	ErrorDetails string            `json:"errorDetails,omitempty"`
+	// Checks lists the connection check followed, for chat models, by the
+	// streaming, tool calling and JSON mode probes
+	Checks []DiagnosticCheck `json:"checks,omitempty"`
+}
+
+// runModelDiagnostics checks that a configuration works the way its
+// capabilities say it will be used: chat models get a completion and are then
+// probed for streaming, tool calling and JSON mode, embedding models get an
+// embedding, and anything else a model lookup. A failing probe only marks the
+// feature unsupported; the test fails when the connection check does.
+func runModelDiagnostics(ctx context.Context, config *models.ModelConfig, decrypt bool) TestModelConfigResponse {
+	client, err := createLLMClientFromConfig(config, decrypt)
+	if err != nil {
+		return TestModelConfigResponse{
+			Success:      false,
+			Message:      "Failed to create LLM client",
+			ErrorClass:   errorClassConfiguration,
+			ErrorDetails: err.Error(),
+		}
+	}
+
+	connection := connectionCheck(ctx, client, config)
+	checks := []DiagnosticCheck{connection}
+	if !connection.Supported {
+		return TestModelConfigResponse{
+			Success:      false,
+			Message:      errorClassMessage(connection.ErrorClass),
+			ErrorClass:   connection.ErrorClass,
+			ErrorDetails: connection.Error,
+			Checks:       checks,
+		}
+	}
+
+	if connection.Name == checkChat {
+		checks = append(checks,
+			runDiagnosticCheck(ctx, checkStreaming, func(ctx context.Context) error {
+				return probeStreaming(ctx, client, config.ModelID)
+			}),
+			runDiagnosticCheck(ctx, checkToolCalling, func(ctx context.Context) error {
+				return probeToolCalling(ctx, client, config.ModelID)
+			}),
+			runDiagnosticCheck(ctx, checkJSONMode, func(ctx context.Context) error {
+				return probeJSONMode(ctx, client, config.ModelID)
+			}),
+		)
+	}
+
+	details := map[string]string{
+		"responseTime":   (time.Duration(connection.LatencyMs) * time.Millisecond).String(),
+		"modelAvailable": "true",
+		"tested":         connection.Name,
+	}
+	return TestModelConfigResponse{
+		Success: true,
+		Message: "Connection successful",
+		Details: details,
+		Checks:  checks,
+	}
+}
+
+// connectionCheck exercises the model the way it will be used: a chat
+// completion for chat models, an embedding for embedding models, otherwise a
+// model lookup
+func connectionCheck(ctx context.Context, client *openai.Client, config *models.ModelConfig) DiagnosticCheck {
+	capabilities := config.CapabilityList()
+	switch {
+	case slices.Contains(capabilities, models.CapabilityChat):
+		return runDiagnosticCheck(ctx, checkChat, func(ctx context.Context) error {
+			_, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+				Model:     openai.ChatModel(config.ModelID), //nolint:unconvert
+				Messages:  []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+				MaxTokens: openai.Int(10),
+			})
+			return err
+		})
+	case slices.Contains(capabilities, models.CapabilityEmbedding):
+		return runDiagnosticCheck(ctx, checkEmbedding, func(ctx context.Context) error {
+			resp, err := client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+				Model: openai.EmbeddingModel(config.ModelID),
+				Input: openai.EmbeddingNewParamsInputUnion{OfString: openai.String("Hello")},
+			})
+			if err == nil && (len(resp.Data) == 0 || len(resp.Data[0].Embedding) == 0) {
+				err = errors.New("the provider returned no embedding")
+			}
+			return err
+		})
+	default:
+		// Rerank has no OpenAI-compatible API, so only check that the model exists
+		return runDiagnosticCheck(ctx, checkModelLookup, func(ctx context.Context) error {
+			_, err := client.Models.Get(ctx, config.ModelID)
+			return err
+		})
+	}
+}
+
+// runDiagnosticCheck runs one check with its own timeout and classifies its error
+func runDiagnosticCheck(ctx context.Context, name string, check func(ctx context.Context) error) DiagnosticCheck {
+	ctx, cancel := context.WithTimeout(ctx, diagnosticTimeout)
+	defer cancel()
+
+	startTime := time.Now()
+	err := check(ctx)
+	result := DiagnosticCheck{
+		Name:      name,
+		Supported: err == nil,
+		LatencyMs: time.Since(startTime).Milliseconds(),
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		result.ErrorClass, result.Error = classifyLLMError(err)
+	}
+	return result
+}
+
+// probeStreaming checks that a streamed completion delivers chunks
+func probeStreaming(ctx context.Context, client *openai.Client, modelID string) error {
+	stream := client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{
+		Model:     openai.ChatModel(modelID), //nolint:unconvert
+		Messages:  []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+		MaxTokens: openai.Int(10),
+	})
+	defer stream.Close()
+
+	chunks := 0
+	for stream.Next() {
+		chunks++
+	}
+	if err := stream.Err(); err != nil {
+		return err
+	}
+	if chunks == 0 {
+		return unsupportedError("the provider returned no stream chunks")
+	}
+	return nil
+}
+
+// probeToolCalling checks that the model calls a tool it is required to call
+func probeToolCalling(ctx context.Context, client *openai.Client, modelID string) error {
+	resp, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Model:    openai.ChatModel(modelID), //nolint:unconvert
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("What time is it in UTC?")},
+		Tools: []openai.ChatCompletionToolParam{{
+			Function: shared.FunctionDefinitionParam{
+				Name:        "get_current_time",
+				Description: openai.String("Get the current time in a time zone"),
+				Parameters: shared.FunctionParameters{
+					"type": "object",
+					"properties": map[string]any{
+						"timezone": map[string]any{"type": "string", "description": "IANA time zone name"},
+					},
+					"required": []string{"timezone"},
+				},
+			},
+		}},
+		ToolChoice: openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String("required")},
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Choices) == 0 || len(resp.Choices[0].Message.ToolCalls) == 0 {
+		return unsupportedError("the model answered without calling the tool")
+	}
+	return nil
+}
+
+// probeJSONMode checks that the model returns valid JSON in JSON mode
+func probeJSONMode(ctx context.Context, client *openai.Client, modelID string) error {
+	resp, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Model:    openai.ChatModel(modelID), //nolint:unconvert
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage(`Reply with the JSON object {"ok": true}.`)},
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
+		},
+		MaxTokens: openai.Int(20),
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Choices) == 0 || !json.Valid([]byte(strings.TrimSpace(resp.Choices[0].Message.Content))) {
+		return unsupportedError("the model did not return valid JSON")
+	}
+	return nil
+}
+
+// unsupportedError is a probe failure where the provider answered but the
+// feature did not work
+type unsupportedError string
+
+func (e unsupportedError) Error() string { return string(e) }
+
+// classifyLLMError returns the error class and a description of an error
+// from the provider, using the status code of API errors
+func classifyLLMError(err error) (string, string) {
+	var unsupported unsupportedError
+	if errors.As(err, &unsupported) {
+		return errorClassUnsupported, unsupported.Error()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errorClassTimeout, "Request timed out after " + diagnosticTimeout.String()
+	}
+
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return errorClassConnection, err.Error()
+	}
+	details := apiErr.Message
+	if details == "" {
+		details = err.Error()
+	}
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+		return errorClassAuth, details
+	case apiErr.StatusCode == http.StatusNotFound:
+		return errorClassModelNotFound, details
+	case apiErr.StatusCode == http.StatusTooManyRequests:
+		return errorClassRateLimit, details
+	case apiErr.StatusCode >= http.StatusInternalServerError:
+		return errorClassServerError, details
+	default:
+		return errorClassBadRequest, details
+	}
+}
+
+// errorClassMessage describes an error class for users
+func errorClassMessage(class string) string {
+	switch class {
+	case errorClassAuth:
+		return "Authentication failed"
+	case errorClassModelNotFound:
+		return "Invalid model ID"
+	case errorClassRateLimit:
+		return "Rate limit exceeded"
+	case errorClassServerError:
+		return "Provider error"
+	case errorClassTimeout:
+		return "Connection timeout"
+	case errorClassBadRequest:
+		return "Request rejected"
+	case errorClassConfiguration:
+		return "Failed to create LLM client"
+	default:
+		return "Connection failed"
+	}
+}
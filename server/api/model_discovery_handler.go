@@ -0,0 +1,169 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"veritas-server/db"
+	"veritas-server/models"
+	"veritas-server/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openai/openai-go"
+)
+
+// discoveryCacheTTL is how long a provider's model list is reused
+const discoveryCacheTTL = 10 * time.Minute
+
+// DiscoverModelsRequest identifies the provider to list models from: a base
+// URL and key, or a saved configuration. With a configuration, baseUrl and
+// apiKey override its stored values, e.g. while the configuration is being edited.
+type DiscoverModelsRequest struct {
+	BaseURL string `json:"baseUrl"`
+	APIKey  string `json:"apiKey"`
+	// ModelConfigID fills in the saved base URL and API key. The stored key is
+	// only sent to the saved base URL; another baseUrl requires apiKey.
+	ModelConfigID string `json:"modelConfigId"`
+	Refresh       bool   `json:"refresh"` // Bypass the cache
+}
+
+// DiscoveredModel is a model offered by a provider
+type DiscoveredModel struct {
+	ID      string `json:"id"`
+	OwnedBy string `json:"ownedBy,omitempty"`
+	Created int64  `json:"created,omitempty"`
+}
+
+// DiscoverModelsResponse lists a provider's models
+type DiscoverModelsResponse struct {
+	Models    []DiscoveredModel `json:"models"`
+	FetchedAt time.Time         `json:"fetchedAt"`
+	Cached    bool              `json:"cached"`
+}
+
+// discoveryCache holds model lists per provider and credential
+type discoveryCache struct {
+	mu      sync.Mutex
+	entries map[string]DiscoverModelsResponse
+}
+
+var modelDiscoveryCache = &discoveryCache{entries: make(map[string]DiscoverModelsResponse)}
+
+// get returns a cached model list that has not expired
+func (d *discoveryCache) get(key string) (DiscoverModelsResponse, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.entries[key]
+	if !ok || time.Since(entry.FetchedAt) > discoveryCacheTTL {
+		delete(d.entries, key)
+		return DiscoverModelsResponse{}, false
+	}
+	return entry, true
+}
+
+// put stores a model list and drops expired entries
+func (d *discoveryCache) put(key string, entry DiscoverModelsResponse) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for k, e := range d.entries {
+		if time.Since(e.FetchedAt) > discoveryCacheTTL {
+			delete(d.entries, k)
+		}
+	}
+	d.entries[key] = entry
+}
+
+// discoveryCacheKey identifies a provider and credential without keeping the key in memory as is
+func discoveryCacheKey(baseURL, apiKey string) string {
+	sum := sha256.Sum256([]byte(baseURL + "\x00" + apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// DiscoverModels lists the models a provider offers through its models API,
+// so the model ID can be picked instead of typed
+func DiscoverModels(c *gin.Context) {
+	var req DiscoverModelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	config := &models.ModelConfig{BaseURL: req.BaseURL, APIKey: req.APIKey}
+	if req.ModelConfigID != "" {
+		var saved models.ModelConfig
+		if err := db.DB.First(&saved, "id = ?", req.ModelConfigID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Configuration not found"})
+			return
+		}
+		sameURL := req.BaseURL == "" || strings.TrimRight(req.BaseURL, "/") == strings.TrimRight(saved.BaseURL, "/")
+		if req.BaseURL == "" {
+			config.BaseURL = saved.BaseURL
+		}
+		if req.APIKey == "" && saved.APIKey != "" {
+			// Never send a stored credential to a URL the caller chose
+			if !sameURL {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "apiKey is required when baseUrl differs from the saved configuration"})
+				return
+			}
+			apiKey, err := services.DecryptAPIKey(saved.APIKey)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt the stored API key"})
+				return
+			}
+			config.APIKey = apiKey
+		}
+	}
+
+	key := discoveryCacheKey(config.BaseURL, config.APIKey)
+	if !req.Refresh {
+		if cached, ok := modelDiscoveryCache.get(key); ok {
+			cached.Cached = true
+			c.JSON(http.StatusOK, cached)
+			return
+		}
+	}
+
+	client, err := createLLMClientFromConfig(config, false) // Key is already plaintext
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to create LLM client: " + err.Error()})
+		return
+	}
+
+	page, err := client.Models.List(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to list models from %s: %v", config.BaseURL, err)
+		var apiErr *openai.Error
+		if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "The provider rejected the API key"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to list models: " + err.Error()})
+		return
+	}
+
+	resp := DiscoverModelsResponse{
+		Models:    make([]DiscoveredModel, 0, len(page.Data)),
+		FetchedAt: time.Now(),
+	}
+	for _, model := range page.Data {
+		resp.Models = append(resp.Models, DiscoveredModel{
+			ID:      model.ID,
+			OwnedBy: model.OwnedBy,
+			Created: model.Created,
+		})
+	}
+	sort.Slice(resp.Models, func(i, j int) bool {
+		return resp.Models[i].ID < resp.Models[j].ID
+	})
+
+	modelDiscoveryCache.put(key, resp)
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+	"veritas-server/db"
+	"veritas-server/models"
+)
+
+const (
+	// defaultHealthCheckInterval is used when MODEL_HEALTH_CHECK_INTERVAL is not set
+	defaultHealthCheckInterval = 5 * time.Minute
+	// healthHistoryRetention is how long health checks are kept
+	healthHistoryRetention = 7 * 24 * time.Hour
+	// healthStaleChecks is how many intervals a health check is trusted for
+	healthStaleChecks = 3
+)
+
+// healthCheckInterval is the interval of the running health checks, zero if they are disabled
+var healthCheckInterval time.Duration
+
+// StartHealthChecks periodically checks every model configuration in the
+// background and records the results. MODEL_HEALTH_CHECK_INTERVAL sets the
+// interval as a Go duration (default 5m); "0" disables the checks.
+func StartHealthChecks() {
+	interval := defaultHealthCheckInterval
+	if raw := os.Getenv("MODEL_HEALTH_CHECK_INTERVAL"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < 0 {
+			log.Printf("Invalid MODEL_HEALTH_CHECK_INTERVAL %q, using %s", raw, defaultHealthCheckInterval)
+		} else {
+			interval = parsed
+		}
+	}
+	if interval == 0 {
+		log.Println("Model health checks disabled")
+		return
+	}
+	healthCheckInterval = interval
+	log.Printf("Checking model health every %s", interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			checkAllModelConfigs(context.Background())
+			<-ticker.C
+		}
+	}()
+}
+
+// checkAllModelConfigs checks every configuration concurrently and prunes old history
+func checkAllModelConfigs(ctx context.Context) {
+	var configs []models.ModelConfig
+	if err := db.DB.Find(&configs).Error; err != nil {
+		log.Printf("Failed to load model configs for health checks: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range configs {
+		wg.Go(func() {
+			check := checkModelHealth(ctx, &configs[i])
+			if err := db.DB.Create(&check).Error; err != nil {
+				log.Printf("Failed to record health check for model config %s: %v", configs[i].ID, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if err := db.DB.Where("created_at < ?", time.Now().Add(-healthHistoryRetention)).Delete(&models.ModelHealthCheck{}).Error; err != nil {
+		log.Printf("Failed to prune health history: %v", err)
+	}
+}
+
+// checkModelHealth runs the connection check of the diagnostics on a configuration
+func checkModelHealth(ctx context.Context, config *models.ModelConfig) models.ModelHealthCheck {
+	check := models.ModelHealthCheck{ModelConfigID: config.ID, Status: models.HealthDown, CreatedAt: time.Now()}
+
+	client, err := createLLMClientFromConfig(config, true) // true = decrypt API key
+	if err != nil {
+		check.ErrorClass = errorClassConfiguration
+		check.Error = err.Error()
+		return check
+	}
+
+	result := connectionCheck(ctx, client, config)
+	check.LatencyMs = result.LatencyMs
+	if result.Supported {
+		check.Status = models.HealthUp
+	} else {
+		check.ErrorClass = result.ErrorClass
+		check.Error = result.Error
+	}
+	return check
+}
+
+// latestHealthCheck returns the most recent health check of a configuration
+// that is still trusted, or nil if there is none
+func latestHealthCheck(configID string) *models.ModelHealthCheck {
+	if healthCheckInterval == 0 {
+		return nil
+	}
+
+	var check models.ModelHealthCheck
+	err := db.DB.Where("model_config_id = ? AND created_at > ?", configID, time.Now().Add(-healthStaleChecks*healthCheckInterval)).
+		Order("created_at desc").First(&check).Error
+	if err != nil {
+		return nil
+	}
+	return &check
+}
+
+// configHealthy reports whether a configuration is not currently marked down.
+// Configurations without a recent health check are assumed to be healthy.
+func configHealthy(configID string) bool {
+	check := latestHealthCheck(configID)
+	return check == nil || check.Status != models.HealthDown
+}
+
+// fallbackConfig returns the configuration to answer in place of primary:
+// the first healthy chat model other than primary, the default first, then by
+// name. With vision set, only vision models qualify.
+func fallbackConfig(primary *models.ModelConfig, vision bool) (*models.ModelConfig, bool) {
+	var candidates []models.ModelConfig
+	if err := db.DB.Where("id != ?", primary.ID).Order("is_default desc, name").Find(&candidates).Error; err != nil {
+		log.Printf("Failed to load fallback candidates: %v", err)
+		return nil, false
+	}
+	return pickFallback(candidates, vision, configHealthy)
+}
+
+// pickFallback returns the first of the ordered candidates that is a chat
+// model, a vision model if vision is set, and healthy
+func pickFallback(candidates []models.ModelConfig, vision bool, healthy func(configID string) bool) (*models.ModelConfig, bool) {
+	for i := range candidates {
+		candidate := &candidates[i]
+		if !candidate.HasCapability(models.CapabilityChat) {
+			continue
+		}
+		if vision && !candidate.HasCapability(models.CapabilityVision) {
+			continue
+		}
+		if healthy(candidate.ID) {
+			return candidate, true
+		}
+	}
+	return nil, false
+}
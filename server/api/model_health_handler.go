@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"veritas-server/db"
+	"veritas-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModelHealthResponse is a configuration's current health and check history
+type ModelHealthResponse struct {
+	ModelConfigID string     `json:"modelConfigId"`
+	Status        string     `json:"status"` // up, down or unknown
+	LastCheckedAt *time.Time `json:"lastCheckedAt"`
+	// Uptime24h is the share of checks in the last 24 hours that were up, nil without checks
+	Uptime24h *float64                      `json:"uptime24h"`
+	Checks    Page[models.ModelHealthCheck] `json:"checks"`
+}
+
+// GetModelConfigHealth returns a configuration's health status and its health
+// check history, newest first
+func GetModelConfigHealth(c *gin.Context) {
+	id := c.Param("id")
+
+	var config models.ModelConfig
+	if err := db.DB.First(&config, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Configuration not found"})
+		return
+	}
+
+	pageReq, err := parsePageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := ModelHealthResponse{ModelConfigID: config.ID, Status: models.HealthUnknown}
+	if check := latestHealthCheck(config.ID); check != nil {
+		response.Status = check.Status
+		response.LastCheckedAt = &check.CreatedAt
+	}
+
+	var counts struct {
+		Total int64
+		Up    int64
+	}
+	if err := db.DB.Model(&models.ModelHealthCheck{}).
+		Select("COUNT(*) AS total, COUNT(*) FILTER (WHERE status = ?) AS up", models.HealthUp).
+		Where("model_config_id = ? AND created_at > ?", config.ID, time.Now().Add(-24*time.Hour)).
+		Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch health history"})
+		return
+	}
+	if counts.Total > 0 {
+		uptime := float64(counts.Up) / float64(counts.Total)
+		response.Uptime24h = &uptime
+	}
+
+	response.Checks, err = fetchPage(db.DB.Model(&models.ModelHealthCheck{}).Where("model_config_id = ?", config.ID), pageReq, true, healthCheckCursor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch health history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func healthCheckCursor(check models.ModelHealthCheck) pageCursor {
+	return pageCursor{CreatedAt: check.CreatedAt, ID: strconv.FormatUint(uint64(check.ID), 10)}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"slices"
+	"testing"
+	"veritas-server/models"
+)
+
+func TestPickFallback(t *testing.T) {
+	defaultChat := models.ModelConfig{ID: "default", IsDefault: true, Capabilities: []string{models.CapabilityChat}}
+	legacyChat := models.ModelConfig{ID: "legacy"} // No capabilities means a chat model
+	embedding := models.ModelConfig{ID: "embedding", Capabilities: []string{models.CapabilityEmbedding}}
+	vision := models.ModelConfig{ID: "vision", Capabilities: []string{models.CapabilityChat, models.CapabilityVision}}
+	candidates := []models.ModelConfig{defaultChat, embedding, legacyChat, vision}
+
+	cases := []struct {
+		name   string
+		vision bool
+		down   []string
+		want   string // Empty for no fallback
+	}{
+		{name: "default first", want: "default"},
+		{name: "next after unhealthy default", down: []string{"default"}, want: "legacy"},
+		{name: "skips non-chat models", down: []string{"default", "legacy"}, want: "vision"},
+		{name: "vision only", vision: true, want: "vision"},
+		{name: "no healthy vision model", vision: true, down: []string{"vision"}},
+		{name: "all down", down: []string{"default", "legacy", "vision"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			healthy := func(configID string) bool { return !slices.Contains(tc.down, configID) }
+
+			fallback, ok := pickFallback(candidates, tc.vision, healthy)
+			got := ""
+			if ok {
+				got = fallback.ID
+			}
+			if got != tc.want {
+				t.Fatalf("pickFallback = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
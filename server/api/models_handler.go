@@ -1,43 +1,45 @@
 package api
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
-	"os"
+	"sort"
+	"veritas-server/db"
+	"veritas-server/models"
+	"veritas-server/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetModels returns the list of available LLM models
+// GetModels returns the configured models with what the model catalog knows
+// about them (context window, tool calling, vision, JSON mode and pricing)
 func GetModels(c *gin.Context) {
-	var modelList []Model
+	var configs []models.ModelConfig
+	if err := db.DB.Find(&configs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve models"})
+		return
+	}
+
+	// Default first, then by name
+	sort.SliceStable(configs, func(i, j int) bool {
+		if configs[i].IsDefault != configs[j].IsDefault {
+			return configs[i].IsDefault
+		}
+		return configs[i].Name < configs[j].Name
+	})
 
-	// Try to load models from environment variable
-	modelsConfig := os.Getenv("AVAILABLE_MODELS")
-	if modelsConfig != "" {
-		log.Println("Loading models from AVAILABLE_MODELS environment variable")
-		// Parse JSON format: [{"id":"model-id","name":"Model Name","description":"Description"}]
-		if err := json.Unmarshal([]byte(modelsConfig), &modelList); err != nil {
-			log.Printf("Failed to parse AVAILABLE_MODELS, using defaults: %v", err)
-			log.Printf("AVAILABLE_MODELS value: %s", modelsConfig)
-			modelList = getDefaultModels()
-		} else {
-			log.Printf("Successfully loaded %d models from AVAILABLE_MODELS", len(modelList))
+	modelList := make([]Model, len(configs))
+	for i := range configs {
+		config := &configs[i]
+		modelList[i] = Model{
+			ID:           config.ID,
+			Name:         config.Name,
+			Provider:     config.Provider,
+			ModelID:      config.ModelID,
+			IsDefault:    config.IsDefault,
+			Capabilities: config.CapabilityList(),
+			ModelInfo:    services.ResolveModelInfo(config),
 		}
-	} else {
-		log.Println("AVAILABLE_MODELS not set, using default models")
-		modelList = getDefaultModels()
 	}
 
 	c.JSON(http.StatusOK, modelList)
 }
-
-// getDefaultModels returns the default list of models
-func getDefaultModels() []Model {
-	return []Model{
-		{ID: "gpt-4o", Name: "GPT-4o", Description: "Most capable model for complex tasks"},
-		{ID: "gpt-4o-mini", Name: "GPT-4o Mini", Description: "Fast and efficient for simple tasks"},
-		{ID: "claude-3-5-sonnet", Name: "Claude 3.5 Sonnet", Description: "High intelligence and speed"},
-	}
-}
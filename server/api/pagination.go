@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// Page is one page of a keyset-paginated listing. Items are always returned in
+// the listing's natural order; the cursors select the neighbouring pages.
+type Page[T any] struct {
+	Items []T `json:"items"`
+	// Total is the number of items matching the filters, across all pages
+	Total int64 `json:"total"`
+	// BeforeCursor fetches the page of older items when passed as ?before=
+	BeforeCursor string `json:"beforeCursor,omitempty"`
+	// AfterCursor fetches the page of newer items when passed as ?after=
+	AfterCursor string `json:"afterCursor,omitempty"`
+}
+
+// pageCursor identifies a row by its position in (created_at, id) order
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// idValue returns the cursor ID as a number for tables with numeric primary keys
+func (p *pageCursor) idValue() any {
+	if id, err := strconv.ParseUint(p.ID, 10, 64); err == nil {
+		return id
+	}
+	return p.ID
+}
+
+// pageRequest holds the pagination query parameters
+type pageRequest struct {
+	Limit  int
+	Before *pageCursor
+	After  *pageCursor
+}
+
+// encodeCursor encodes a cursor as an opaque URL-safe string
+func encodeCursor(cursor pageCursor) string {
+	raw := fmt.Sprintf("%d|%s", cursor.CreatedAt.UnixNano(), cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor produced by encodeCursor
+func decodeCursor(encoded string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	nanos, id, found := strings.Cut(string(raw), "|")
+	if !found || id == "" {
+		return nil, errors.New("invalid cursor")
+	}
+
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	return &pageCursor{CreatedAt: time.Unix(0, unixNano), ID: id}, nil
+}
+
+// parsePageRequest reads the limit, before and after query parameters
+func parsePageRequest(c *gin.Context) (pageRequest, error) {
+	req := pageRequest{Limit: defaultPageSize}
+
+	if limit := c.Query("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 {
+			return req, errors.New("limit must be a positive integer")
+		}
+		req.Limit = min(value, maxPageSize)
+	}
+
+	if before := c.Query("before"); before != "" {
+		cursor, err := decodeCursor(before)
+		if err != nil {
+			return req, err
+		}
+		req.Before = cursor
+	}
+
+	if after := c.Query("after"); after != "" {
+		if req.Before != nil {
+			return req, errors.New("before and after cannot be combined")
+		}
+		cursor, err := decodeCursor(after)
+		if err != nil {
+			return req, err
+		}
+		req.After = cursor
+	}
+
+	return req, nil
+}
+
+// fetchPage loads one page from query using keyset pagination on (created_at, id).
+// Without a cursor, the newest items are returned. newestFirst selects the
+// order of the returned items: descending for listings like the conversation
+// sidebar, ascending (chronological) for message histories.
+func fetchPage[T any](query *gorm.DB, req pageRequest, newestFirst bool, cursorOf func(T) pageCursor) (Page[T], error) {
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return Page[T]{Items: []T{}}, err
+	}
+
+	// Walk away from the cursor: backwards in time for "before", forwards for "after"
+	paged := query.Session(&gorm.Session{})
+	switch {
+	case req.Before != nil:
+		paged = paged.Where("created_at < ? OR (created_at = ? AND id < ?)", req.Before.CreatedAt, req.Before.CreatedAt, req.Before.idValue())
+	case req.After != nil:
+		paged = paged.Where("created_at > ? OR (created_at = ? AND id > ?)", req.After.CreatedAt, req.After.CreatedAt, req.After.idValue())
+	}
+	if req.After != nil {
+		paged = paged.Order("created_at asc").Order("id asc")
+	} else {
+		paged = paged.Order("created_at desc").Order("id desc")
+	}
+
+	var rows []T
+	if err := paged.Limit(req.Limit + 1).Find(&rows).Error; err != nil {
+		return Page[T]{Items: []T{}}, err
+	}
+
+	page := pageFromRows(rows, req, newestFirst, cursorOf)
+	page.Total = total
+	return page, nil
+}
+
+// pageFromRows builds a page from up to req.Limit+1 rows, ordered away from
+// the cursor (oldest first after an "after" cursor, otherwise newest first).
+// The extra row only tells that more items exist beyond the page.
+func pageFromRows[T any](rows []T, req pageRequest, newestFirst bool, cursorOf func(T) pageCursor) Page[T] {
+	page := Page[T]{Items: []T{}}
+	forward := req.After != nil
+
+	hasMore := len(rows) > req.Limit
+	if hasMore {
+		rows = rows[:req.Limit]
+	}
+
+	// Rows are ordered away from the cursor; put them in the requested order
+	if forward == newestFirst {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	page.Items = append(page.Items, rows...)
+
+	if len(rows) == 0 {
+		return page
+	}
+
+	oldest, newest := rows[0], rows[len(rows)-1]
+	if newestFirst {
+		oldest, newest = newest, oldest
+	}
+
+	// Older items exist if we walked backwards and hit the limit, or walked forwards from a cursor
+	if forward || hasMore {
+		page.BeforeCursor = encodeCursor(cursorOf(oldest))
+	}
+	// Newer items exist if we walked forwards and hit the limit, or walked backwards from a cursor
+	if (forward && hasMore) || req.Before != nil {
+		page.AfterCursor = encodeCursor(cursorOf(newest))
+	}
+
+	return page
+}
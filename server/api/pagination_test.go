@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := pageCursor{CreatedAt: time.Date(2025, 6, 1, 12, 30, 0, 123456789, time.UTC), ID: "c1f0|with-separator"}
+	got, err := decodeCursor(encodeCursor(want))
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Fatalf("decodeCursor = %+v, want %+v", got, want)
+	}
+
+	for _, encoded := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("1700000000")),
+		base64.RawURLEncoding.EncodeToString([]byte("1700000000|")),
+		base64.RawURLEncoding.EncodeToString([]byte("yesterday|42")),
+	} {
+		if _, err := decodeCursor(encoded); err == nil {
+			t.Errorf("decodeCursor(%q) succeeded", encoded)
+		}
+	}
+}
+
+// pagedItem stands in for a database row in the pagination tests
+type pagedItem struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func pagedItemCursor(item pagedItem) pageCursor {
+	return pageCursor{CreatedAt: item.CreatedAt, ID: item.ID}
+}
+
+// comparePagedItems orders items by (created_at, id), as fetchPage does
+func comparePagedItems(a, b pagedItem) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// queryRows returns the rows fetchPage's query selects for req from items
+// sorted oldest first
+func queryRows(items []pagedItem, req pageRequest) []pagedItem {
+	var rows []pagedItem
+	switch {
+	case req.After != nil:
+		for _, item := range items {
+			if comparePagedItems(item, pagedItem(*req.After)) > 0 {
+				rows = append(rows, item)
+			}
+		}
+	default:
+		for i := len(items) - 1; i >= 0; i-- {
+			if req.Before == nil || comparePagedItems(items[i], pagedItem(*req.Before)) < 0 {
+				rows = append(rows, items[i])
+			}
+		}
+	}
+	return rows[:min(len(rows), req.Limit+1)]
+}
+
+func TestPageFromRows(t *testing.T) {
+	// Items share timestamps so that the ID breaks ties
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var items []pagedItem
+	for i := range 11 {
+		items = append(items, pagedItem{CreatedAt: base.Add(time.Duration(i/2) * time.Minute), ID: fmt.Sprintf("%02d", i)})
+	}
+
+	for _, newestFirst := range []bool{true, false} {
+		for _, limit := range []int{1, 3, 11, 20} {
+			t.Run(fmt.Sprintf("newestFirst=%v/limit=%d", newestFirst, limit), func(t *testing.T) {
+				fetch := func(req pageRequest) Page[pagedItem] {
+					page := pageFromRows(queryRows(items, req), req, newestFirst, pagedItemCursor)
+					if !slices.IsSortedFunc(page.Items, func(a, b pagedItem) int {
+						if newestFirst {
+							return comparePagedItems(b, a)
+						}
+						return comparePagedItems(a, b)
+					}) {
+						t.Fatalf("page items out of order: %v", page.Items)
+					}
+					return page
+				}
+				cursor := func(encoded string) *pageCursor {
+					decoded, err := decodeCursor(encoded)
+					if err != nil {
+						t.Fatalf("decodeCursor: %v", err)
+					}
+					return decoded
+				}
+
+				// Walk back from the newest page to the oldest
+				var seen []pagedItem
+				page := fetch(pageRequest{Limit: limit})
+				if page.AfterCursor != "" {
+					t.Fatalf("the newest page has an after cursor")
+				}
+				for {
+					seen = append(seen, page.Items...)
+					if page.BeforeCursor == "" {
+						break
+					}
+					page = fetch(pageRequest{Limit: limit, Before: cursor(page.BeforeCursor)})
+					if page.AfterCursor == "" {
+						t.Fatalf("a page before the newest one has no after cursor")
+					}
+				}
+				slices.SortFunc(seen, comparePagedItems)
+				if !slices.Equal(seen, items) {
+					t.Fatalf("walking back returned %v, want every item once", seen)
+				}
+
+				// And forward again from the oldest item
+				seen = items[:1]
+				page = fetch(pageRequest{Limit: limit, After: cursor(encodeCursor(pagedItemCursor(items[0])))})
+				for {
+					if page.BeforeCursor == "" {
+						t.Fatalf("a page after a cursor has no before cursor")
+					}
+					seen = append(seen, page.Items...)
+					if page.AfterCursor == "" {
+						break
+					}
+					page = fetch(pageRequest{Limit: limit, After: cursor(page.AfterCursor)})
+				}
+				slices.SortFunc(seen, comparePagedItems)
+				if !slices.Equal(seen, items) {
+					t.Fatalf("walking forward returned %v, want every item once", seen)
+				}
+			})
+		}
+	}
+
+	empty := pageFromRows(nil, pageRequest{Limit: 10}, true, pagedItemCursor)
+	if empty.Items == nil || empty.BeforeCursor != "" || empty.AfterCursor != "" {
+		t.Fatalf("empty page = %+v", empty)
+	}
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"veritas-server/db"
+	"veritas-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openai/openai-go"
+)
+
+// proxyUsage is the usage object of an OpenAI chat completion or stream chunk
+type proxyUsage struct {
+	Usage *struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// ProxyModel is a model in the OpenAI /v1/models format
+type ProxyModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// RequireProxyAPIKey protects the OpenAI-compatible endpoints with the bearer
+// token in PROXY_API_KEY. Without PROXY_API_KEY the endpoints are open, like
+// the rest of the API.
+func RequireProxyAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := os.Getenv("PROXY_API_KEY")
+		if expected == "" {
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			openAIError(c, http.StatusUnauthorized, "Invalid API key", "invalid_request_error", "invalid_api_key")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// ListProxyModels lists the chat model configurations in the OpenAI /v1/models
+// format. Model IDs are configuration names.
+func ListProxyModels(c *gin.Context) {
+	var configs []models.ModelConfig
+	if err := db.DB.Order("name asc").Find(&configs).Error; err != nil {
+		openAIError(c, http.StatusInternalServerError, "Failed to retrieve models", "server_error", "")
+		return
+	}
+
+	data := []ProxyModel{}
+	for _, config := range configs {
+		if !config.HasCapability(models.CapabilityChat) {
+			continue
+		}
+		data = append(data, ProxyModel{
+			ID:      config.Name,
+			Object:  "model",
+			Created: config.CreatedAt.Unix(),
+			OwnedBy: config.Provider,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": data})
+}
+
+// ProxyChatCompletions forwards an OpenAI chat completion request to the model
+// configuration named by "model", using its stored credentials. The request
+// and response bodies pass through unchanged apart from the model ID, and
+// streaming responses are relayed as they arrive. If the provider is
+// unavailable, the default configuration answers instead. Every request is
+// recorded as a UsageRecord.
+func ProxyChatCompletions(c *gin.Context) {
+	startTime := time.Now()
+
+	var body map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&body); err != nil {
+		openAIError(c, http.StatusBadRequest, "Invalid JSON body: "+err.Error(), "invalid_request_error", "")
+		return
+	}
+
+	var modelName string
+	if err := json.Unmarshal(body["model"], &modelName); err != nil || modelName == "" {
+		openAIError(c, http.StatusBadRequest, "model is required", "invalid_request_error", "")
+		return
+	}
+	var stream bool
+	if raw, ok := body["stream"]; ok {
+		_ = json.Unmarshal(raw, &stream)
+	}
+	// Ask for usage in the final stream chunk so streamed requests can be accounted for
+	if _, ok := body["stream_options"]; stream && !ok {
+		body["stream_options"] = json.RawMessage(`{"include_usage":true}`)
+	}
+
+	record := models.UsageRecord{
+		RequestedModel: modelName,
+		Endpoint:       "chat.completions",
+		Stream:         stream,
+	}
+	defer func() {
+		record.LatencyMs = time.Since(startTime).Milliseconds()
+		if err := db.DB.Create(&record).Error; err != nil {
+			log.Printf("Failed to record proxy usage: %v", err)
+		}
+	}()
+
+	var requested models.ModelConfig
+	if err := db.DB.First(&requested, "name = ?", modelName).Error; err != nil {
+		record.StatusCode, record.Error = http.StatusNotFound, "model not found"
+		openAIError(c, http.StatusNotFound, "The model '"+modelName+"' does not exist", "invalid_request_error", "model_not_found")
+		return
+	}
+	if !requested.HasCapability(models.CapabilityChat) {
+		record.StatusCode, record.Error = http.StatusBadRequest, "not a chat model"
+		openAIError(c, http.StatusBadRequest, "The model '"+modelName+"' is not a chat model", "invalid_request_error", "")
+		return
+	}
+
+	var lastErr error
+	for i, config := range proxyCandidates(&requested) {
+		record.ModelConfigID = config.ID
+		record.Fallback = i > 0
+
+		res, err := sendProxyRequest(c, &config, body)
+		if err == nil {
+			record.StatusCode = res.StatusCode
+			relayProxyResponse(c, res, stream, &record)
+			return
+		}
+
+		lastErr = err
+		log.Printf("Proxy request to %s failed: %v", config.Name, err)
+		if !shouldFallBack(err) {
+			break
+		}
+	}
+
+	// Relay the provider's error, or report that it could not be reached
+	record.Error = lastErr.Error()
+	var apiErr *openai.Error
+	if errors.As(lastErr, &apiErr) && apiErr.Response != nil {
+		record.StatusCode = apiErr.StatusCode
+		content, _ := io.ReadAll(apiErr.Response.Body)
+		c.Data(apiErr.StatusCode, "application/json", content)
+		return
+	}
+	record.StatusCode = http.StatusBadGateway
+	openAIError(c, http.StatusBadGateway, "The model provider could not be reached", "server_error", "")
+}
+
+// proxyCandidates returns the configurations to try: the requested one, then
+// the first healthy chat configuration as a fallback, the default first
+func proxyCandidates(requested *models.ModelConfig) []models.ModelConfig {
+	candidates := []models.ModelConfig{*requested}
+	if fallback, ok := fallbackConfig(requested, requested.HasCapability(models.CapabilityVision)); ok {
+		candidates = append(candidates, *fallback)
+	}
+	return candidates
+}
+
+// shouldFallBack reports whether another configuration might succeed where
+// this error occurred: the provider was unreachable, overloaded or failing,
+// as opposed to rejecting the request itself
+func shouldFallBack(err error) bool {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// sendProxyRequest sends the request body to a configuration's provider with
+// its model ID. The caller must close the response body.
+func sendProxyRequest(c *gin.Context, config *models.ModelConfig, body map[string]json.RawMessage) (*http.Response, error) {
+	client, err := createLLMClientFromConfig(config, true)
+	if err != nil {
+		return nil, err
+	}
+
+	upstream := make(map[string]json.RawMessage, len(body))
+	for key, value := range body {
+		upstream[key] = value
+	}
+	upstream["model"], err = json.Marshal(config.ModelID)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *http.Response
+	if err := client.Post(c.Request.Context(), "chat/completions", payload, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// relayProxyResponse copies the provider's response to the client, recording
+// the token usage it reports. Streams are flushed line by line.
+func relayProxyResponse(c *gin.Context, res *http.Response, stream bool, record *models.UsageRecord) {
+	defer res.Body.Close()
+
+	contentType := res.Header.Get("Content-Type")
+	if !stream {
+		content, err := io.ReadAll(res.Body)
+		if err != nil {
+			record.Error = err.Error()
+			openAIError(c, http.StatusBadGateway, "Failed to read the provider's response", "server_error", "")
+			return
+		}
+		recordProxyUsage(content, record)
+		c.Data(res.StatusCode, contentType, content)
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(res.StatusCode)
+
+	reader := bufio.NewReader(res.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if data, ok := bytes.CutPrefix(line, []byte("data: ")); ok {
+				recordProxyUsage(data, record)
+			}
+			if _, writeErr := c.Writer.Write(line); writeErr != nil {
+				record.Error = "client disconnected"
+				return
+			}
+			c.Writer.Flush()
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				record.Error = err.Error()
+			}
+			return
+		}
+	}
+}
+
+// recordProxyUsage adds the token usage reported in a response or stream chunk
+func recordProxyUsage(data []byte, record *models.UsageRecord) {
+	var usage proxyUsage
+	if err := json.Unmarshal(bytes.TrimSpace(data), &usage); err == nil && usage.Usage != nil {
+		record.PromptTokens += usage.Usage.PromptTokens
+		record.CompletionTokens += usage.Usage.CompletionTokens
+	}
+}
+
+// openAIError writes an error in the OpenAI API format, which clients of the
+// proxy endpoints expect instead of the usual {"error": "..."}
+func openAIError(c *gin.Context, status int, message, errType, code string) {
+	body := gin.H{"message": message, "type": errType}
+	if code != "" {
+		body["code"] = code
+	}
+	c.JSON(status, gin.H{"error": body})
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"errors"
+	"html"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"veritas-server/db"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+
+	// Highlight delimiters used by ts_headline; replaced by <mark> tags after HTML escaping
+	highlightStart = "\x01"
+	highlightStop  = "\x02"
+)
+
+// SearchResult is a single search hit: a message, or a conversation whose title matched
+type SearchResult struct {
+	ConversationID    string    `json:"conversationId"`
+	ConversationTitle string    `json:"conversationTitle"`
+	MessageID         *uint     `json:"messageId,omitempty"` // Empty for title matches
+	Role              string    `json:"role,omitempty"`
+	ModelConfigID     string    `json:"modelConfigId,omitempty"`
+	Highlight         string    `json:"highlight"` // HTML-escaped snippet with matches wrapped in <mark>
+	Rank              float64   `json:"rank"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// SearchResponse holds search hits ordered by relevance
+type SearchResponse struct {
+	Query           string         `json:"query"`
+	Results         []SearchResult `json:"results"`
+	ConversationIDs []string       `json:"conversationIds"`
+	MessageIDs      []uint         `json:"messageIds"`
+}
+
+// Search performs a full-text search across message content and conversation titles.
+// Query parameters:
+//   - q: search terms, supports web search syntax ("quoted phrases", -excluded, or)
+//   - from, to: date range as RFC 3339 timestamps or YYYY-MM-DD dates (inclusive, see parseDateRange)
+//   - modelConfigId, role: only match messages with this model configuration or role
+//   - limit: maximum number of results (default 20, max 100)
+func Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter q is required"})
+		return
+	}
+
+	dates, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if raw := c.Query("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(value, maxSearchLimit)
+	}
+
+	role := c.Query("role")
+	modelConfigID := c.Query("modelConfigId")
+	headlineOptions := `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `", MaxFragments=2, MaxWords=30, MinWords=10`
+
+	var results []SearchResult
+
+	messageQuery := db.DB.Table("messages AS m").
+		Select(`c.id AS conversation_id, c.title AS conversation_title, m.id AS message_id, m.role, m.model_config_id,
+			ts_headline('simple', m.content, query, ?) AS highlight,
+			ts_rank(m.content_tsv, query) AS rank, m.created_at`, headlineOptions).
+		Joins("JOIN conversations AS c ON c.id = m.conversation_id AND c.deleted_at IS NULL").
+		Joins("CROSS JOIN websearch_to_tsquery('simple', ?) AS query", q).
+		Where("m.content_tsv @@ query")
+	messageQuery = dates.apply(messageQuery, "m.created_at")
+	if role != "" {
+		messageQuery = messageQuery.Where("m.role = ?", role)
+	}
+	if modelConfigID != "" {
+		messageQuery = messageQuery.Where("m.model_config_id = ?", modelConfigID)
+	}
+	if err := messageQuery.Order("rank DESC").Order("m.created_at DESC").Limit(limit).Scan(&results).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search messages"})
+		return
+	}
+
+	// Titles have no role or model, so they only match when those filters are not used
+	if role == "" && modelConfigID == "" {
+		var titleResults []SearchResult
+		titleQuery := db.DB.Table("conversations AS c").
+			Select(`c.id AS conversation_id, c.title AS conversation_title,
+				ts_headline('simple', c.title, query, ?) AS highlight,
+				ts_rank(c.title_tsv, query) AS rank, c.created_at`, headlineOptions).
+			Joins("CROSS JOIN websearch_to_tsquery('simple', ?) AS query", q).
+			Where("c.deleted_at IS NULL AND c.title_tsv @@ query")
+		titleQuery = dates.apply(titleQuery, "c.created_at")
+		if err := titleQuery.Order("rank DESC").Order("c.created_at DESC").Limit(limit).Scan(&titleResults).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search conversations"})
+			return
+		}
+		results = append(results, titleResults...)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Rank > results[j].Rank
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	response := SearchResponse{
+		Query:           q,
+		Results:         []SearchResult{},
+		ConversationIDs: []string{},
+		MessageIDs:      []uint{},
+	}
+	seenConversations := make(map[string]bool)
+	for _, result := range results {
+		result.Highlight = renderHighlight(result.Highlight)
+		response.Results = append(response.Results, result)
+
+		if !seenConversations[result.ConversationID] {
+			seenConversations[result.ConversationID] = true
+			response.ConversationIDs = append(response.ConversationIDs, result.ConversationID)
+		}
+		if result.MessageID != nil {
+			response.MessageIDs = append(response.MessageIDs, *result.MessageID)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// dateRange is a from/to filter on a timestamp column. Both ends are
+// inclusive: an RFC 3339 to includes that instant, and a YYYY-MM-DD to includes
+// the whole day, so it is stored as an exclusive bound at the next midnight.
+type dateRange struct {
+	from        *time.Time
+	to          *time.Time
+	toExclusive bool
+}
+
+// parseDateRange reads the optional from and to query parameters, each an
+// RFC 3339 timestamp or a YYYY-MM-DD date in server local time
+func parseDateRange(c *gin.Context) (dateRange, error) {
+	var dates dateRange
+	var err error
+	if dates.from, _, err = parseDate(c.Query("from")); err != nil {
+		return dates, errors.New("invalid from date: " + err.Error())
+	}
+
+	var dateOnly bool
+	if dates.to, dateOnly, err = parseDate(c.Query("to")); err != nil {
+		return dates, errors.New("invalid to date: " + err.Error())
+	}
+	if dates.to != nil && dateOnly {
+		nextDay := dates.to.AddDate(0, 0, 1)
+		dates.to, dates.toExclusive = &nextDay, true
+	}
+	return dates, nil
+}
+
+// apply restricts query to rows whose column lies within the range
+func (r dateRange) apply(query *gorm.DB, column string) *gorm.DB {
+	if r.from != nil {
+		query = query.Where(column+" >= ?", *r.from)
+	}
+	if r.to != nil {
+		if r.toExclusive {
+			query = query.Where(column+" < ?", *r.to)
+		} else {
+			query = query.Where(column+" <= ?", *r.to)
+		}
+	}
+	return query
+}
+
+// parseDate parses an RFC 3339 timestamp or a YYYY-MM-DD date, reporting
+// whether the value was a date without a time
+func parseDate(raw string) (*time.Time, bool, error) {
+	if raw == "" {
+		return nil, false, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, false, nil
+	}
+
+	t, err := time.ParseInLocation(time.DateOnly, raw, time.Local)
+	if err != nil {
+		return nil, false, errors.New("expected RFC 3339 timestamp or YYYY-MM-DD date")
+	}
+	return &t, true, nil
+}
+
+// renderHighlight escapes a ts_headline snippet and turns the match delimiters into <mark> tags
+func renderHighlight(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+	"veritas-server/db"
+	"veritas-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// shareTokenBytes is the amount of randomness in a share token
+const shareTokenBytes = 32
+
+// CreateShareRequest configures a new share link
+type CreateShareRequest struct {
+	ExpiresAt      *time.Time `json:"expiresAt"`
+	ExpiresInHours *int       `json:"expiresInHours"`
+}
+
+// SharedConversationResponse is the public view of a shared conversation
+type SharedConversationResponse struct {
+	Title     string                 `json:"title"`
+	SharedAt  time.Time              `json:"sharedAt"`
+	ExpiresAt *time.Time             `json:"expiresAt"`
+	Messages  []models.SharedMessage `json:"messages"`
+}
+
+// CreateShare publishes a snapshot of the conversation's active branch under a
+// new share token. Later changes to the conversation do not affect the snapshot.
+func CreateShare(c *gin.Context) {
+	var req CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var expiresAt *time.Time
+	switch {
+	case req.ExpiresAt != nil && req.ExpiresInHours != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set either expiresAt or expiresInHours, not both"})
+		return
+	case req.ExpiresAt != nil:
+		if !req.ExpiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt must be in the future"})
+			return
+		}
+		expiresAt = req.ExpiresAt
+	case req.ExpiresInHours != nil:
+		if *req.ExpiresInHours <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expiresInHours must be positive"})
+			return
+		}
+		expiry := time.Now().Add(time.Duration(*req.ExpiresInHours) * time.Hour)
+		expiresAt = &expiry
+	}
+
+	var conv models.Conversation
+	if err := db.DB.First(&conv, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	branch, err := activeBranch(&conv)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+	if len(branch) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot share an empty conversation"})
+		return
+	}
+
+	messages := make([]models.SharedMessage, len(branch))
+	for i, msg := range branch {
+		messages[i] = models.SharedMessage{
+			Role:      msg.Role,
+			Content:   msg.Content,
+			CreatedAt: msg.CreatedAt,
+		}
+	}
+	snapshot, err := json.Marshal(messages)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share"})
+		return
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share"})
+		return
+	}
+
+	share := models.SharedConversation{
+		Token:          token,
+		ConversationID: conv.ID,
+		Title:          conv.Title,
+		Snapshot:       string(snapshot),
+		ExpiresAt:      expiresAt,
+	}
+	if err := db.DB.Create(&share).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, share)
+}
+
+// GetShares lists the share links of a conversation, including revoked and expired ones
+func GetShares(c *gin.Context) {
+	var shares []models.SharedConversation
+	if err := db.DB.
+		Where("conversation_id = ?", c.Param("id")).
+		Order("created_at desc").
+		Find(&shares).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shares"})
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+// RevokeShare revokes a share link so its token no longer resolves
+func RevokeShare(c *gin.Context) {
+	var share models.SharedConversation
+	if err := db.DB.
+		Where("conversation_id = ? AND token = ?", c.Param("id"), c.Param("token")).
+		First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return
+	}
+
+	if share.RevokedAt == nil {
+		now := time.Now()
+		if err := db.DB.Model(&share).Update("revoked_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, share)
+}
+
+// GetSharedConversation returns a shared snapshot by token. It needs no other
+// credentials; unknown, revoked and expired tokens, and tokens of conversations
+// in the trash, all respond with 404.
+func GetSharedConversation(c *gin.Context) {
+	var share models.SharedConversation
+	err := db.DB.
+		Joins("JOIN conversations ON conversations.id = shared_conversations.conversation_id AND conversations.deleted_at IS NULL").
+		Where("shared_conversations.token = ?", c.Param("token")).
+		First(&share).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shared conversation"})
+		return
+	}
+	if err != nil || !share.IsAvailable(time.Now()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shared conversation not found or no longer available"})
+		return
+	}
+
+	var messages []models.SharedMessage
+	if err := json.Unmarshal([]byte(share.Snapshot), &messages); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read shared conversation"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, SharedConversationResponse{
+		Title:     share.Title,
+		SharedAt:  share.CreatedAt,
+		ExpiresAt: share.ExpiresAt,
+		Messages:  messages,
+	})
+}
+
+// newShareToken generates a random URL-safe token
+func newShareToken() (string, error) {
+	buf := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
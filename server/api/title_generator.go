@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"veritas-server/db"
+	"veritas-server/models"
+
+	"github.com/openai/openai-go"
+)
+
+const (
+	// defaultConversationTitle is used until a better title is available
+	defaultConversationTitle = "New Chat"
+	// fallbackTitleLength is the length of titles truncated from the first message
+	fallbackTitleLength = 30
+	// maxGeneratedTitleLength caps titles returned by the LLM
+	maxGeneratedTitleLength = 60
+	// titleGenerationTimeout bounds a single title generation request
+	titleGenerationTimeout = 30 * time.Second
+)
+
+const titlePrompt = "Write a short, descriptive title (at most 6 words) for the conversation below. " +
+	"Use the language of the conversation. Reply with the title only, without quotes or trailing punctuation."
+
+// generateConversationTitle creates a fallback title from the first message
+func generateConversationTitle(message string) string {
+	title := truncateRunes(strings.Join(strings.Fields(message), " "), fallbackTitleLength)
+	if title == "" {
+		return defaultConversationTitle
+	}
+	return title
+}
+
+// truncateRunes shortens s to at most maxRunes characters, adding "..." when cut.
+// Unlike byte slicing, it never splits a multi-byte UTF-8 character.
+func truncateRunes(s string, maxRunes int) string {
+	if utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+	return string([]rune(s)[:maxRunes]) + "..."
+}
+
+// titleModelConfig returns the model configuration used for title generation:
+// TITLE_MODEL_CONFIG (a config ID or name) if set, otherwise the given config,
+// otherwise the default config
+func titleModelConfig(modelConfigID string) (*models.ModelConfig, error) {
+	var config models.ModelConfig
+
+	if configured := os.Getenv("TITLE_MODEL_CONFIG"); configured != "" {
+		var titleConfig models.ModelConfig
+		err := db.DB.Where("id = ? OR name = ?", configured, configured).First(&titleConfig).Error
+		if err == nil && titleConfig.HasCapability(models.CapabilityChat) {
+			return &titleConfig, nil
+		}
+		log.Printf("TITLE_MODEL_CONFIG %q not found or not a chat model, using the conversation's model", configured)
+	}
+
+	if modelConfigID != "" {
+		if err := db.DB.First(&config, "id = ?", modelConfigID).Error; err == nil {
+			return &config, nil
+		}
+	}
+
+	if err := db.DB.Where("is_default = ?", true).First(&config).Error; err != nil {
+		return nil, errors.New("no model configuration available for title generation")
+	}
+	return &config, nil
+}
+
+// generateTitleWithLLM asks an LLM for a title based on the first exchange of
+// the conversation's active branch
+func generateTitleWithLLM(ctx context.Context, conversationID, modelConfigID string) (string, error) {
+	var conv models.Conversation
+	if err := db.DB.First(&conv, "id = ?", conversationID).Error; err != nil {
+		return "", err
+	}
+	leafID, err := activeLeaf(&conv)
+	if err != nil {
+		return "", err
+	}
+	branch, err := branchMessages(conversationID, leafID)
+	if err != nil {
+		return "", err
+	}
+	firstMessages := firstExchange(branch)
+	if len(firstMessages) == 0 {
+		return "", errors.New("conversation has no messages")
+	}
+
+	var transcript strings.Builder
+	for _, m := range firstMessages {
+		fmt.Fprintf(&transcript, "%s: %s\n", m.Role, truncateRunes(m.Content, 2000))
+	}
+
+	config, err := titleModelConfig(modelConfigID)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := createLLMClientFromConfig(config, true)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, titleGenerationTimeout)
+	defer cancel()
+
+	resp, err := client.Chat.Completions.New(
+		ctx,
+		openai.ChatCompletionNewParams{
+			Model: openai.ChatModel(config.ModelID), //nolint:unconvert
+			Messages: []openai.ChatCompletionMessageParamUnion{
+				openai.SystemMessage(titlePrompt),
+				openai.UserMessage(transcript.String()),
+			},
+			MaxTokens: openai.Int(32),
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("LLM returned no choices")
+	}
+
+	title := cleanGeneratedTitle(resp.Choices[0].Message.Content)
+	if title == "" {
+		return "", errors.New("LLM returned an empty title")
+	}
+	return title, nil
+}
+
+// firstExchange returns the first user message of a branch and the answer that
+// follows it, if any
+func firstExchange(branch []models.Message) []models.Message {
+	for i, msg := range branch {
+		if msg.Role != "user" {
+			continue
+		}
+		if i+1 < len(branch) && branch[i+1].Role == "assistant" {
+			return branch[i : i+2]
+		}
+		return branch[i : i+1]
+	}
+	return nil
+}
+
+// cleanGeneratedTitle keeps the first line of an LLM reply and strips quotes and punctuation
+func cleanGeneratedTitle(raw string) string {
+	title := strings.TrimSpace(raw)
+	if line, _, found := strings.Cut(title, "\n"); found {
+		title = line
+	}
+	title = strings.TrimPrefix(title, "Title:")
+	title = strings.Trim(title, " \t\"'`*#“”‘’「」")
+	title = strings.TrimRight(title, ".。!！")
+	return truncateRunes(strings.TrimSpace(title), maxGeneratedTitleLength)
+}
+
+// generateTitleAsync replaces a conversation's placeholder title with an LLM-generated one
+// in the background. The title is only replaced if it still equals placeholder, so a
+// title the user set in the meantime is never overwritten.
+func generateTitleAsync(conversationID, modelConfigID, placeholder string) {
+	go func() {
+		title, err := generateTitleWithLLM(context.Background(), conversationID, modelConfigID)
+		if err != nil {
+			log.Printf("Failed to generate title for conversation %s, keeping fallback title: %v", conversationID, err)
+			return
+		}
+
+		if err := db.DB.Model(&models.Conversation{}).
+			Where("id = ? AND title = ?", conversationID, placeholder).
+			Update("title", title).Error; err != nil {
+			log.Printf("Failed to save generated title for conversation %s: %v", conversationID, err)
+		}
+	}()
+}
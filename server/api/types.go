@@ -1,17 +1,30 @@
 package api
 
-// Model represents an available LLM model
+import "veritas-server/services"
+
+// Model is a configured model enriched with model catalog data
 type Model struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	ID           string   `json:"id"` // Model configuration ID
+	Name         string   `json:"name"`
+	Provider     string   `json:"provider"`
+	ModelID      string   `json:"modelId"`
+	IsDefault    bool     `json:"isDefault"`
+	Capabilities []string `json:"capabilities"`
+	services.ModelInfo
 }
 
 // ChatRequest represents a chat message request
 type ChatRequest struct {
-	ModelConfigID  string `json:"modelConfigId"` // ID of the model configuration to use
-	Message        string `json:"message"`
-	ConversationID string `json:"conversationId"`
+	ModelConfigID  string   `json:"modelConfigId"` // ID of the model configuration to use
+	Message        string   `json:"message"`
+	ConversationID string   `json:"conversationId"`
+	ImageIDs       []string `json:"imageIds,omitempty"` // Uploaded images to send with the message; requires a vision model
+	// KnowledgeBaseIDs restricts the search_knowledge_base tool to these collections; empty allows all
+	KnowledgeBaseIDs []string `json:"knowledgeBaseIds,omitempty"`
+	// AllowFallback lets another configuration answer while this one is marked
+	// down. It is only set when the configuration was defaulted or inherited
+	// rather than chosen by the caller, who otherwise gets the error of their model.
+	AllowFallback bool `json:"-"`
 }
 
 // ChatResponse represents a chat message response
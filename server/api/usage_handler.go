@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"veritas-server/db"
+	"veritas-server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageSummary aggregates proxy usage for one model configuration
+type UsageSummary struct {
+	ModelConfigID    string  `json:"modelConfigId"`
+	Name             string  `json:"name"`
+	Requests         int64   `json:"requests"`
+	Errors           int64   `json:"errors"`
+	Fallbacks        int64   `json:"fallbacks"`
+	PromptTokens     int64   `json:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens"`
+	AvgLatencyMs     float64 `json:"avgLatencyMs"`
+}
+
+// GetUsage summarizes requests served through the OpenAI-compatible proxy per
+// model configuration. Optional from/to select an inclusive date range, as in search.
+func GetUsage(c *gin.Context) {
+	dates, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.DB.Model(&models.UsageRecord{}).
+		Select(`model_config_id,
+			COUNT(*) AS requests,
+			COUNT(*) FILTER (WHERE status_code >= 400 OR error != '') AS errors,
+			COUNT(*) FILTER (WHERE fallback) AS fallbacks,
+			COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens,
+			COALESCE(SUM(completion_tokens), 0) AS completion_tokens,
+			COALESCE(AVG(latency_ms), 0) AS avg_latency_ms`).
+		Group("model_config_id").
+		Order("requests desc")
+	query = dates.apply(query, "created_at")
+
+	var summaries []UsageSummary
+	if err := query.Scan(&summaries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarize usage"})
+		return
+	}
+
+	names := loadModelNames()
+	for i := range summaries {
+		summaries[i].Name = names[summaries[i].ModelConfigID]
+	}
+	if summaries == nil {
+		summaries = []UsageSummary{}
+	}
+
+	c.JSON(http.StatusOK, summaries)
+}
@@ -13,11 +13,11 @@ import (
 var DB *gorm.DB
 
 func Init() {
-	// Validate encryption key before proceeding
+	// Validate the secret backend and its keys before proceeding
 	if err := services.ValidateEncryptionKey(); err != nil {
 		log.Fatal("Encryption key validation failed: ", err)
 	}
-	log.Println("Encryption key validated successfully")
+	log.Println("Secret backend validated successfully")
 
 	dsn := fmt.Sprintf(
 		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Asia/Shanghai",
@@ -47,15 +47,168 @@ func Init() {
 	// Remove NOT NULL constraint from api_key to support local models like Ollama
 	DB.Exec("ALTER TABLE model_configs ALTER COLUMN api_key DROP NOT NULL")
 
+	// Names only need to be unique among configurations that are not deleted,
+	// so the old unique index is replaced by a partial one during migration
+	DB.Exec("DROP INDEX IF EXISTS idx_model_configs_name")
+
 	// Auto Migrate (will add NOT NULL constraints)
-	err = DB.AutoMigrate(&models.Conversation{}, &models.Message{}, &models.ModelConfig{})
+	err = DB.AutoMigrate(
+		&models.Conversation{},
+		&models.Message{},
+		&models.ModelConfig{},
+		&models.ArenaVote{},
+		&models.SharedConversation{},
+		&models.Attachment{},
+		&models.AttachmentChunk{},
+		&models.KnowledgeCollection{},
+		&models.KnowledgeDocument{},
+		&models.KnowledgeChunk{},
+		&models.Image{},
+		&models.UsageRecord{},
+		&models.ModelHealthCheck{},
+	)
 	if err != nil {
 		log.Fatal("Failed to migrate database: ", err)
 	}
 	log.Println("Database migrated successfully")
 
+	setupFullTextSearch()
+	setupVectorSearch()
+	migrateMessageTree()
+	backfillMessageModelNames()
+
 	// Run default model config migration
 	if err := services.MigrateDefaultModelConfig(DB); err != nil {
 		log.Printf("Warning: Failed to migrate default model config: %v", err)
 	}
+
+	// Encrypt any API keys still stored in plain text
+	if err := services.EncryptPlaintextAPIKeys(DB); err != nil {
+		log.Printf("Warning: Failed to encrypt plaintext API keys: %v", err)
+	}
+
+	// Report configurations whose API keys cannot be decrypted with the current keys
+	services.LogStoredAPIKeyCheck(DB)
+}
+
+// setupFullTextSearch adds generated tsvector columns with GIN indexes for searching
+// message content and conversation titles. The 'simple' configuration is used
+// because conversations mix languages and stemming would favour English.
+func setupFullTextSearch() {
+	statements := []string{
+		"ALTER TABLE messages ADD COLUMN IF NOT EXISTS content_tsv tsvector GENERATED ALWAYS AS (to_tsvector('simple', coalesce(content, ''))) STORED",
+		"CREATE INDEX IF NOT EXISTS idx_messages_content_tsv ON messages USING GIN (content_tsv)",
+		"ALTER TABLE conversations ADD COLUMN IF NOT EXISTS title_tsv tsvector GENERATED ALWAYS AS (to_tsvector('simple', coalesce(title, ''))) STORED",
+		"CREATE INDEX IF NOT EXISTS idx_conversations_title_tsv ON conversations USING GIN (title_tsv)",
+	}
+
+	for _, statement := range statements {
+		if err := DB.Exec(statement).Error; err != nil {
+			log.Printf("Warning: Failed to set up full-text search: %v", err)
+			return
+		}
+	}
+}
+
+// MaxIndexedDimensions is the largest embedding dimension pgvector can index with HNSW
+const MaxIndexedDimensions = 2000
+
+// setupVectorSearch enables the pgvector extension and adds the embedding
+// column of knowledge base chunks. The column has no fixed dimension because
+// collections may use different embedding models, so each dimension in use
+// gets its own index, see EnsureVectorIndex. Without pgvector the server
+// still starts, but knowledge bases cannot be used.
+func setupVectorSearch() {
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS vector",
+		"ALTER TABLE knowledge_chunks ADD COLUMN IF NOT EXISTS embedding vector",
+	}
+
+	for _, statement := range statements {
+		if err := DB.Exec(statement).Error; err != nil {
+			log.Printf("Warning: Failed to set up pgvector, knowledge bases are unavailable: %v", err)
+			return
+		}
+	}
+
+	var dimensions []int
+	if err := DB.Model(&models.KnowledgeCollection{}).Where("dimensions > 0").Distinct().Pluck("dimensions", &dimensions).Error; err != nil {
+		log.Printf("Warning: Failed to load knowledge base dimensions: %v", err)
+		return
+	}
+	for _, d := range dimensions {
+		if err := EnsureVectorIndex(d); err != nil {
+			log.Printf("Warning: Failed to index %d-dimensional embeddings, searches will scan every chunk: %v", d, err)
+		}
+	}
+}
+
+// EnsureVectorIndex creates the HNSW index for embeddings of one dimension.
+// pgvector only indexes columns of a fixed dimension, so the index is on the
+// embedding cast to that dimension and covers only chunks of that length;
+// VectorDistance writes searches the way this index expects. Dimensions above
+// MaxIndexedDimensions cannot be indexed and are searched by a full scan.
+func EnsureVectorIndex(dimensions int) error {
+	if dimensions <= 0 || dimensions > MaxIndexedDimensions {
+		return nil
+	}
+	return DB.Exec(fmt.Sprintf(
+		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_knowledge_chunks_embedding_%[1]d ON knowledge_chunks "+
+			"USING hnsw ((embedding::vector(%[1]d)) vector_cosine_ops) WHERE vector_dims(embedding) = %[1]d",
+		dimensions,
+	)).Error
+}
+
+// VectorDistance returns the cosine distance between the chunk embedding
+// column and a query vector parameter, and the condition that restricts chunks
+// to the query's dimension, written so that the index of EnsureVectorIndex applies
+func VectorDistance(column string, dimensions int) (distance, condition string) {
+	distance = fmt.Sprintf("%[1]s::vector(%[2]d) <=> ?::vector(%[2]d)", column, dimensions)
+	condition = fmt.Sprintf("vector_dims(%s) = %d", column, dimensions)
+	return distance, condition
+}
+
+// migrateMessageTree links the messages of conversations created before messages
+// formed a tree: each message's parent becomes the message before it, and the
+// last message becomes the active leaf. Conversations that already have an
+// active leaf are left alone, so the migration is safe to run on every startup.
+func migrateMessageTree() {
+	err := DB.Exec(`
+		UPDATE messages m SET parent_id = ordered.prev_id
+		FROM (
+			SELECT id, LAG(id) OVER (PARTITION BY conversation_id ORDER BY created_at, id) AS prev_id
+			FROM messages
+		) ordered
+		JOIN conversations c ON c.active_leaf_id IS NULL
+		WHERE m.id = ordered.id AND m.conversation_id = c.id
+			AND m.parent_id IS NULL AND ordered.prev_id IS NOT NULL`).Error
+	if err != nil {
+		log.Printf("Warning: Failed to link legacy messages into a tree: %v", err)
+		return
+	}
+
+	err = DB.Exec(`
+		UPDATE conversations c SET active_leaf_id = (
+			SELECT m.id FROM messages m
+			WHERE m.conversation_id = c.id
+			ORDER BY m.created_at DESC, m.id DESC
+			LIMIT 1
+		)
+		WHERE c.active_leaf_id IS NULL`).Error
+	if err != nil {
+		log.Printf("Warning: Failed to set active leaf of legacy conversations: %v", err)
+	}
+}
+
+// backfillMessageModelNames snapshots the model configuration name on messages
+// written before names were stored with them. Messages that already have a
+// name are left alone, so renamed configurations keep their old names there.
+func backfillMessageModelNames() {
+	err := DB.Exec(`
+		UPDATE messages m SET model_name = mc.name
+		FROM model_configs mc
+		WHERE m.model_config_id = mc.id AND (m.model_name IS NULL OR m.model_name = '')`).Error
+	if err != nil {
+		log.Printf("Warning: Failed to backfill model names of messages: %v", err)
+	}
 }
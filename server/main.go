@@ -24,12 +24,15 @@ func main() {
 	// Initialize Database
 	db.Init()
 
+	// Check model configurations in the background
+	api.StartHealthChecks()
+
 	r := gin.Default()
 
 	// CORS configuration
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"http://localhost:3000"}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
 	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept"}
 	r.Use(cors.New(config))
 
@@ -38,9 +41,42 @@ func main() {
 	{
 		apiGroup.GET("/models", api.GetModels)
 		apiGroup.POST("/chat", api.Chat)
+		apiGroup.POST("/chat/compare", api.CompareChat)
+		apiGroup.POST("/arena/votes", api.VoteArena)
+		apiGroup.GET("/arena/leaderboard", api.GetArenaLeaderboard)
 		apiGroup.GET("/conversations", api.GetConversations)
+		apiGroup.GET("/conversations/export", api.ExportAllConversations)
+		apiGroup.POST("/conversations/import", api.ImportConversations)
 		apiGroup.GET("/conversations/:id", api.GetConversation)
+		apiGroup.GET("/conversations/:id/export", api.ExportConversation)
+		apiGroup.GET("/conversations/:id/messages", api.GetMessages)
+		apiGroup.PUT("/conversations/:id/messages/:msgId", api.EditMessage)
+		apiGroup.POST("/conversations/:id/messages/:msgId/regenerate", api.RegenerateMessage)
+		apiGroup.PUT("/conversations/:id/branch", api.SwitchBranch)
 		apiGroup.POST("/conversations", api.CreateConversation)
+		apiGroup.PATCH("/conversations/:id", api.UpdateConversation)
+		apiGroup.DELETE("/conversations/:id", api.DeleteConversation)
+		apiGroup.POST("/conversations/:id/restore", api.RestoreConversation)
+		apiGroup.POST("/conversations/:id/title", api.RegenerateConversationTitle)
+		apiGroup.POST("/conversations/:id/attachments", api.UploadAttachments)
+		apiGroup.GET("/conversations/:id/attachments", api.GetAttachments)
+		apiGroup.DELETE("/conversations/:id/attachments/:attachmentId", api.DeleteAttachment)
+		apiGroup.POST("/conversations/:id/shares", api.CreateShare)
+		apiGroup.GET("/conversations/:id/shares", api.GetShares)
+		apiGroup.DELETE("/conversations/:id/shares/:token", api.RevokeShare)
+		apiGroup.GET("/shared/:token", api.GetSharedConversation)
+		apiGroup.GET("/search", api.Search)
+		apiGroup.POST("/images", api.UploadImage)
+		apiGroup.GET("/images/:id", api.GetImage)
+
+		// Knowledge base endpoints
+		apiGroup.POST("/knowledge-bases", api.CreateKnowledgeBase)
+		apiGroup.GET("/knowledge-bases", api.GetKnowledgeBases)
+		apiGroup.POST("/knowledge-bases/search", api.SearchKnowledgeBases)
+		apiGroup.GET("/knowledge-bases/:id", api.GetKnowledgeBase)
+		apiGroup.DELETE("/knowledge-bases/:id", api.DeleteKnowledgeBase)
+		apiGroup.POST("/knowledge-bases/:id/documents", api.AddKnowledgeDocuments)
+		apiGroup.DELETE("/knowledge-bases/:id/documents/:documentId", api.DeleteKnowledgeDocument)
 
 		// Model configuration endpoints
 		apiGroup.POST("/model-configs", api.CreateModelConfig)
@@ -49,6 +85,22 @@ func main() {
 		apiGroup.PUT("/model-configs/:id", api.UpdateModelConfig)
 		apiGroup.DELETE("/model-configs/:id", api.DeleteModelConfig)
 		apiGroup.POST("/model-configs/test", api.TestModelConfig)
+		apiGroup.POST("/model-configs/:id/test", api.TestSavedModelConfig)
+		apiGroup.GET("/model-configs/:id/health", api.GetModelConfigHealth)
+		apiGroup.POST("/model-configs/discover", api.DiscoverModels)
+		apiGroup.POST("/embeddings", api.CreateEmbeddings)
+		apiGroup.GET("/usage", api.GetUsage)
+
+		// Encryption key management
+		apiGroup.POST("/encryption/rotate", api.RotateEncryptionKeys)
+		apiGroup.GET("/encryption/check", api.CheckEncryptionKeys)
+	}
+
+	// OpenAI-compatible proxy, addressed by model configuration name
+	v1Group := r.Group("/v1", api.RequireProxyAPIKey())
+	{
+		v1Group.GET("/models", api.ListProxyModels)
+		v1Group.POST("/chat/completions", api.ProxyChatCompletions)
 	}
 
 	log.Println("Server starting on :8080")
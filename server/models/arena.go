@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// ArenaVote records which answer of an arena comparison the user preferred
+type ArenaVote struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	ComparisonID        string    `gorm:"not null;uniqueIndex" json:"comparisonId"` // One vote per comparison, voting again replaces it
+	ConversationID      string    `gorm:"not null;index" json:"conversationId"`
+	WinnerMessageID     *uint     `json:"winnerMessageId"` // Nil for a tie
+	WinnerModelConfigID string    `json:"winnerModelConfigId,omitempty"`
+	CreatedAt           time.Time `json:"createdAt"`
+	UpdatedAt           time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Attachment is a document uploaded to a conversation. Its extracted text is
+// split into chunks that are given to the model as context.
+type Attachment struct {
+	ID             string    `gorm:"primaryKey" json:"id"`
+	ConversationID string    `gorm:"not null;index" json:"conversationId"`
+	FileName       string    `gorm:"not null" json:"fileName"`
+	ContentType    string    `json:"contentType"`
+	Size           int64     `json:"size"`
+	CharCount      int       `json:"charCount"` // Length of the extracted text in characters
+	ChunkCount     int       `json:"chunkCount"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// AttachmentChunk is a piece of an attachment's extracted text
+type AttachmentChunk struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	AttachmentID string `gorm:"not null;index" json:"attachmentId"`
+	Index        int    `gorm:"not null" json:"index"` // Position of the chunk within the attachment
+	Content      string `gorm:"type:text;not null" json:"content"`
+}
@@ -2,20 +2,42 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
+// Conversation is a tree of messages. The branch shown to the user and sent to
+// the LLM as history is the path from the root to ActiveLeafID.
 type Conversation struct {
-	ID        string    `gorm:"primaryKey" json:"id"`
-	Title     string    `json:"title"`
-	CreatedAt time.Time `json:"createdAt"`
-	Messages  []Message `gorm:"foreignKey:ConversationID" json:"messages"`
+	ID           string         `gorm:"primaryKey" json:"id"`
+	Title        string         `json:"title"`
+	Pinned       bool           `gorm:"default:false" json:"pinned"`
+	Archived     bool           `gorm:"default:false" json:"archived"`
+	ActiveLeafID *uint          `json:"activeLeafId"` // Last message of the selected branch
+	CreatedAt    time.Time      `json:"createdAt"`
+	UpdatedAt    time.Time      `json:"updatedAt"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deletedAt"` // Soft delete, conversation can be restored
+	Messages     []Message      `gorm:"foreignKey:ConversationID" json:"messages"`
 }
 
+// Message is a node in a conversation's message tree. Messages sharing a parent
+// are alternative versions, e.g. an edited question or a regenerated answer.
 type Message struct {
-	ID             uint      `gorm:"primaryKey" json:"id"`
-	ConversationID string    `json:"conversationId"`
-	Role           string    `json:"role"`
-	Content        string    `json:"content"`
-	ModelConfigID  string    `json:"modelConfigId"` // Track which model was used
-	CreatedAt      time.Time `json:"createdAt"`
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	ConversationID   string     `json:"conversationId"`
+	ParentID         *uint      `gorm:"index" json:"parentId"` // Nil for the first message of a branch
+	Role             string     `json:"role"`
+	Content          string     `json:"content"`
+	ModelConfigID    string     `json:"modelConfigId"`                       // Track which model was used
+	ModelName        string     `json:"modelName,omitempty"`                 // Name of the model configuration when the message was written
+	ComparisonID     string     `gorm:"index" json:"comparisonId,omitempty"` // Groups answers of one arena comparison
+	LatencyMs        int64      `json:"latencyMs,omitempty"`                 // Time the LLM took to answer
+	PromptTokens     int64      `json:"promptTokens,omitempty"`
+	CompletionTokens int64      `json:"completionTokens,omitempty"`
+	Failed           bool       `gorm:"default:false" json:"failed,omitempty"`               // Set on answers whose LLM call failed; Content holds the error
+	ImageIDs         []string   `gorm:"serializer:json;type:text" json:"imageIds,omitempty"` // Images attached to a user message
+	CreatedAt        time.Time  `json:"createdAt"`
+	EditedAt         *time.Time `json:"editedAt,omitempty"`            // Set on user messages created by editing another message
+	SiblingIDs       []uint     `gorm:"-" json:"siblingIds,omitempty"` // Alternative versions, including this message
+	Variants         []Message  `gorm:"-" json:"variants,omitempty"`   // Alternative answers to the same question, including this one
 }
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// Health statuses of a model configuration
+const (
+	HealthUp      = "up"
+	HealthDown    = "down"
+	HealthUnknown = "unknown" // Never checked, or the last check is too old to trust
+)
+
+// ModelHealthCheck is the result of one periodic health check of a model configuration
+type ModelHealthCheck struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ModelConfigID string    `gorm:"not null;index:idx_model_health_checks_config_created" json:"modelConfigId"`
+	Status        string    `gorm:"not null" json:"status"` // HealthUp or HealthDown
+	LatencyMs     int64     `json:"latencyMs"`
+	ErrorClass    string    `json:"errorClass,omitempty"`
+	Error         string    `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt     time.Time `gorm:"index:idx_model_health_checks_config_created;index" json:"createdAt"`
+}
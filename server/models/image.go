@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// Image is an uploaded image that can be attached to chat messages. Images are
+// uploaded first and belong to the conversation of the first message using them.
+type Image struct {
+	ID             string    `gorm:"primaryKey" json:"id"`
+	ConversationID string    `gorm:"index" json:"conversationId"` // Empty until the image is sent in a message
+	ContentType    string    `gorm:"not null" json:"contentType"`
+	Size           int64     `json:"size"`
+	Data           []byte    `gorm:"type:bytea;not null" json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// KnowledgeCollection is a named set of documents that the agent can search.
+// All chunks of a collection are embedded with the same model configuration.
+type KnowledgeCollection struct {
+	ID                     string    `gorm:"primaryKey" json:"id"`
+	Name                   string    `gorm:"not null;uniqueIndex" json:"name"`
+	Description            string    `json:"description"`
+	EmbeddingModelConfigID string    `gorm:"not null" json:"embeddingModelConfigId"`
+	Dimensions             int       `json:"dimensions"` // Set by the first ingested document
+	DocumentCount          int64     `gorm:"-" json:"documentCount"`
+	CreatedAt              time.Time `json:"createdAt"`
+	UpdatedAt              time.Time `json:"updatedAt"`
+}
+
+// KnowledgeDocument is a document ingested into a knowledge collection
+type KnowledgeDocument struct {
+	ID           string    `gorm:"primaryKey" json:"id"`
+	CollectionID string    `gorm:"not null;index" json:"collectionId"`
+	Title        string    `gorm:"not null" json:"title"`
+	Source       string    `json:"source"` // Where the document came from, e.g. a URL or file name
+	CharCount    int       `json:"charCount"`
+	ChunkCount   int       `json:"chunkCount"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// KnowledgeChunk is an embedded piece of a knowledge document. Its embedding
+// is stored in a pgvector "embedding" column that is managed with raw SQL.
+type KnowledgeChunk struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	DocumentID   string `gorm:"not null;index" json:"documentId"`
+	CollectionID string `gorm:"not null;index" json:"collectionId"`
+	Index        int    `gorm:"not null" json:"index"`
+	Content      string `gorm:"type:text;not null" json:"content"`
+}
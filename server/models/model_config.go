@@ -1,44 +1,100 @@
 package models
 
 import (
+	"slices"
 	"time"
+
+	"gorm.io/gorm"
+)
+
+// Model capabilities
+const (
+	CapabilityChat      = "chat"
+	CapabilityEmbedding = "embedding"
+	CapabilityVision    = "vision" // Accepts images in chat messages; requires chat
+	CapabilityRerank    = "rerank"
 )
 
+// Capabilities lists the valid capabilities in their canonical order
+var Capabilities = []string{CapabilityChat, CapabilityEmbedding, CapabilityVision, CapabilityRerank}
+
 // ModelConfig represents a configured LLM model with connection details
 type ModelConfig struct {
-	ID        string    `gorm:"primaryKey" json:"id"`
-	Name      string    `gorm:"not null;uniqueIndex" json:"name"`
-	Provider  string    `gorm:"not null" json:"provider"`
-	BaseURL   string    `json:"baseUrl"`
-	ModelID   string    `gorm:"not null" json:"modelId"`
-	APIKey    string    `json:"-"` // Encrypted, never sent to client. Optional for local models like Ollama
-	IsDefault bool      `gorm:"default:false" json:"isDefault"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID               string                `gorm:"primaryKey" json:"id"`
+	Name             string                `gorm:"not null;uniqueIndex:idx_model_configs_active_name,where:deleted_at IS NULL" json:"name"` // Unique among configurations that are not deleted
+	Provider         string                `gorm:"not null" json:"provider"`
+	BaseURL          string                `json:"baseUrl"`
+	ModelID          string                `gorm:"not null" json:"modelId"`
+	APIKey           string                `json:"-"` // Encrypted, never sent to client. Optional for local models like Ollama
+	IsDefault        bool                  `gorm:"default:false" json:"isDefault"`
+	Capabilities     []string              `gorm:"serializer:json;type:text" json:"capabilities"` // Empty for configs created before capabilities existed, which are chat models
+	CatalogOverrides *ModelCatalogOverride `gorm:"serializer:json;type:text" json:"catalogOverrides"`
+	LastTestedAt     *time.Time            `json:"lastTestedAt"` // Nil until the saved configuration is tested
+	LastTestSuccess  bool                  `gorm:"default:false" json:"lastTestSuccess"`
+	LastTestMessage  string                `json:"lastTestMessage"`
+	CreatedAt        time.Time             `json:"createdAt"`
+	UpdatedAt        time.Time             `json:"updatedAt"`
+	DeletedAt        gorm.DeletedAt        `gorm:"index" json:"deletedAt"` // Soft delete, hidden from selection while messages keep referring to it
+}
+
+// ModelCatalogOverride replaces model catalog values for one configuration,
+// e.g. for models missing from the catalog or with negotiated prices. Nil
+// fields keep the catalog value. Vision support is not overridden here: it is
+// the configuration's vision capability.
+type ModelCatalogOverride struct {
+	ContextWindow         *int     `json:"contextWindow,omitempty"`
+	MaxOutputTokens       *int     `json:"maxOutputTokens,omitempty"`
+	ToolCalling           *bool    `json:"toolCalling,omitempty"`
+	JSONMode              *bool    `json:"jsonMode,omitempty"`
+	InputPricePerMillion  *float64 `json:"inputPricePerMillion,omitempty"`
+	OutputPricePerMillion *float64 `json:"outputPricePerMillion,omitempty"`
 }
 
 // ModelConfigResponse is the sanitized version sent to clients
 type ModelConfigResponse struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Provider  string    `json:"provider"`
-	BaseURL   string    `json:"baseUrl"`
-	ModelID   string    `json:"modelId"`
-	IsDefault bool      `json:"isDefault"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID               string                `json:"id"`
+	Name             string                `json:"name"`
+	Provider         string                `json:"provider"`
+	BaseURL          string                `json:"baseUrl"`
+	ModelID          string                `json:"modelId"`
+	IsDefault        bool                  `json:"isDefault"`
+	Capabilities     []string              `json:"capabilities"`
+	CatalogOverrides *ModelCatalogOverride `json:"catalogOverrides"`
+	LastTestedAt     *time.Time            `json:"lastTestedAt"`
+	LastTestSuccess  bool                  `json:"lastTestSuccess"`
+	LastTestMessage  string                `json:"lastTestMessage"`
+	CreatedAt        time.Time             `json:"createdAt"`
+	UpdatedAt        time.Time             `json:"updatedAt"`
 }
 
 // ToResponse converts ModelConfig to ModelConfigResponse (masks API key)
 func (m *ModelConfig) ToResponse() ModelConfigResponse {
 	return ModelConfigResponse{
-		ID:        m.ID,
-		Name:      m.Name,
-		Provider:  m.Provider,
-		BaseURL:   m.BaseURL,
-		ModelID:   m.ModelID,
-		IsDefault: m.IsDefault,
-		CreatedAt: m.CreatedAt,
-		UpdatedAt: m.UpdatedAt,
+		ID:               m.ID,
+		Name:             m.Name,
+		Provider:         m.Provider,
+		BaseURL:          m.BaseURL,
+		ModelID:          m.ModelID,
+		IsDefault:        m.IsDefault,
+		Capabilities:     m.CapabilityList(),
+		CatalogOverrides: m.CatalogOverrides,
+		LastTestedAt:     m.LastTestedAt,
+		LastTestSuccess:  m.LastTestSuccess,
+		LastTestMessage:  m.LastTestMessage,
+		CreatedAt:        m.CreatedAt,
+		UpdatedAt:        m.UpdatedAt,
 	}
 }
+
+// CapabilityList returns the model's capabilities, defaulting to chat
+func (m *ModelConfig) CapabilityList() []string {
+	if len(m.Capabilities) == 0 {
+		return []string{CapabilityChat}
+	}
+	return m.Capabilities
+}
+
+// HasCapability reports whether the model supports a capability
+func (m *ModelConfig) HasCapability(capability string) bool {
+	return slices.Contains(m.CapabilityList(), capability)
+}
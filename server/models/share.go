@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// SharedConversation is a read-only snapshot of a conversation published under
+// an unguessable token
+type SharedConversation struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Token          string     `gorm:"not null;uniqueIndex" json:"token"`
+	ConversationID string     `gorm:"not null;index" json:"conversationId"`
+	Title          string     `json:"title"`
+	Snapshot       string     `gorm:"type:text;not null" json:"-"` // JSON encoded []SharedMessage
+	ExpiresAt      *time.Time `json:"expiresAt"`
+	RevokedAt      *time.Time `json:"revokedAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// SharedMessage is a message as published in a share snapshot. It deliberately
+// omits model config IDs, token usage and other private metadata.
+type SharedMessage struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IsAvailable reports whether the share can still be viewed
+func (s *SharedConversation) IsAvailable(now time.Time) bool {
+	return s.RevokedAt == nil && (s.ExpiresAt == nil || now.Before(*s.ExpiresAt))
+}
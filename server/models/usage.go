@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// UsageRecord accounts for one request served through the OpenAI-compatible proxy
+type UsageRecord struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	ModelConfigID    string    `gorm:"index" json:"modelConfigId"`     // Configuration that served the request, empty if none could
+	RequestedModel   string    `gorm:"not null" json:"requestedModel"` // Model name sent by the client
+	Endpoint         string    `gorm:"not null" json:"endpoint"`
+	Stream           bool      `json:"stream"`
+	Fallback         bool      `json:"fallback"` // Served by a fallback configuration
+	StatusCode       int       `json:"statusCode"`
+	PromptTokens     int64     `json:"promptTokens"`
+	CompletionTokens int64     `json:"completionTokens"`
+	LatencyMs        int64     `json:"latencyMs"`
+	Error            string    `json:"error,omitempty"`
+	CreatedAt        time.Time `gorm:"index" json:"createdAt"`
+}
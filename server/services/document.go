@@ -0,0 +1,208 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// ErrUnsupportedDocument is returned for files whose type cannot be extracted
+var ErrUnsupportedDocument = errors.New("unsupported document type: expected PDF, DOCX, Markdown or plain text")
+
+// DocumentTypes lists the supported file extensions
+var DocumentTypes = map[string]string{
+	".pdf":      "application/pdf",
+	".docx":     "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".md":       "text/markdown",
+	".markdown": "text/markdown",
+	".txt":      "text/plain",
+}
+
+// ExtractDocumentText extracts the plain text of a PDF, DOCX, Markdown or text
+// file. The type is taken from the file extension.
+func ExtractDocumentText(fileName string, data []byte) (string, error) {
+	var text string
+	var err error
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".pdf":
+		text, err = extractPDFText(data)
+	case ".docx":
+		text, err = extractDOCXText(data)
+	case ".md", ".markdown", ".txt":
+		if !utf8.Valid(data) {
+			return "", errors.New("text file is not valid UTF-8")
+		}
+		text = string(data)
+	default:
+		return "", ErrUnsupportedDocument
+	}
+	if err != nil {
+		return "", err
+	}
+
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return "", errors.New("document contains no extractable text")
+	}
+	return text, nil
+}
+
+// extractPDFText extracts the text layer of every page. Scanned PDFs without a
+// text layer yield no text.
+func extractPDFText(data []byte) (text string, err error) {
+	// The PDF parser panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("failed to parse PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse PDF: %w", err)
+	}
+
+	var pages []string
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		pageText, err := page.GetPlainText(nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to read PDF page %d: %w", i, err)
+		}
+		if strings.TrimSpace(pageText) != "" {
+			pages = append(pages, pageText)
+		}
+	}
+
+	return strings.Join(pages, "\n\n"), nil
+}
+
+// extractDOCXText extracts the paragraphs of a Word document's main body
+func extractDOCXText(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open DOCX: %w", err)
+	}
+
+	var document *zip.File
+	for _, file := range archive.File {
+		if file.Name == "word/document.xml" {
+			document = file
+			break
+		}
+	}
+	if document == nil {
+		return "", errors.New("failed to open DOCX: word/document.xml not found")
+	}
+
+	content, err := document.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open DOCX: %w", err)
+	}
+	defer content.Close()
+
+	var text strings.Builder
+	decoder := xml.NewDecoder(content)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse DOCX: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				text.WriteByte('\t')
+			case "br", "cr":
+				text.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text.WriteString("\n\n")
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		}
+	}
+
+	return text.String(), nil
+}
+
+// ChunkText splits text into chunks of at most size runes, overlapping by
+// overlap runes. Chunks end at paragraph, line or sentence boundaries where
+// possible so that each one reads on its own.
+func ChunkText(text string, size, overlap int) []string {
+	runes := []rune(strings.TrimSpace(text))
+	if size <= 0 || overlap >= size {
+		return nil
+	}
+
+	var chunks []string
+	for start := 0; start < len(runes); {
+		end := start + size
+		if end >= len(runes) {
+			end = len(runes)
+		} else {
+			end = chunkBoundary(runes, start+size/2, end)
+		}
+
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+
+		// Step back by the overlap, then forward to the start of a word
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		for next < end && !unicode.IsSpace(runes[next-1]) {
+			next++
+		}
+		start = next
+	}
+
+	return chunks
+}
+
+// chunkBoundary returns the best position in (min, max] to end a chunk:
+// preferably after a paragraph break, then a line break, a sentence or a word
+func chunkBoundary(runes []rune, min, max int) int {
+	separators := []string{"\n\n", "\n", ". ", " "}
+	for _, sep := range separators {
+		sepRunes := []rune(sep)
+		for i := max - len(sepRunes); i >= min; i-- {
+			if string(runes[i:i+len(sepRunes)]) == sep {
+				return i + len(sepRunes)
+			}
+		}
+	}
+	return max
+}
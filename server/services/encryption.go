@@ -13,36 +13,49 @@ import (
 )
 
 const (
-	keyVersion = "v1"
+	// legacyKeyVersion marks ciphertexts produced before key rotation was supported.
+	// Format: v1:nonce:ciphertext, always encrypted with the legacy key.
+	legacyKeyVersion = "v1"
+	// keyVersion marks ciphertexts that embed the ID of the key used to encrypt them.
+	// Format: v2:keyID:nonce:ciphertext
+	keyVersion = "v2"
+	// legacyKeyID is the ID given to the key from ENCRYPTION_KEY in the keyring
+	legacyKeyID = "default"
+	// gcmNonceSize and gcmTagSize are the sizes used by crypto/cipher's standard AES-GCM
+	gcmNonceSize = 12
+	gcmTagSize   = 16
 )
 
-// EncryptAPIKey encrypts an API key using AES-256-GCM
-func EncryptAPIKey(plaintext string) (string, error) {
-	key, err := getEncryptionKey()
-	if err != nil {
-		return "", err
-	}
+// Keyring is the local SecretStore. It holds every encryption key known to
+// the server, indexed by ID. New values are always encrypted with the active
+// key; any key in the ring can be used for decryption so old ciphertexts stay
+// readable during rotation.
+type Keyring struct {
+	keys     map[string][]byte
+	activeID string
+}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
-	}
+// ActiveKeyID returns the ID of the key used for new encryptions
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeID
+}
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
-	}
+// Name identifies the local backend
+func (k *Keyring) Name() string {
+	return SecretBackendLocal
+}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", fmt.Errorf("failed to generate nonce: %w", err)
+// Encrypt encrypts a secret using AES-256-GCM with the active key
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	nonce, ciphertext, err := sealAESGCM(k.keys[k.activeID], []byte(plaintext))
+	if err != nil {
+		return "", err
 	}
 
-	ciphertext := gcm.Seal(nil, nonce, []byte(plaintext), nil)
-
-	// Format: version:nonce:ciphertext (all base64 encoded)
-	result := fmt.Sprintf("%s:%s:%s",
+	// Format: version:keyID:nonce:ciphertext (nonce and ciphertext base64 encoded)
+	result := fmt.Sprintf("%s:%s:%s:%s",
 		keyVersion,
+		k.activeID,
 		base64.StdEncoding.EncodeToString(nonce),
 		base64.StdEncoding.EncodeToString(ciphertext),
 	)
@@ -50,83 +63,216 @@ func EncryptAPIKey(plaintext string) (string, error) {
 	return result, nil
 }
 
-// DecryptAPIKey decrypts an encrypted API key
-// If the input is not in encrypted format (e.g., plain text), returns it as-is
-func DecryptAPIKey(encrypted string) (string, error) {
-	// If empty, return empty
-	if encrypted == "" {
-		return "", nil
+// Decrypt decrypts a v1 or v2 ciphertext
+func (k *Keyring) Decrypt(encrypted string) (string, error) {
+	return k.decrypt(encrypted)
+}
+
+// Owns reports whether a value is a v1 or v2 ciphertext
+func (k *Keyring) Owns(encrypted string) bool {
+	return ownsLocalCiphertext(encrypted)
+}
+
+// IsCurrent reports whether a value was encrypted with the active key
+func (k *Keyring) IsCurrent(encrypted string) bool {
+	return EncryptionKeyID(encrypted) == k.activeID
+}
+
+// decrypt opens a v1 or v2 ciphertext
+func (k *Keyring) decrypt(encrypted string) (string, error) {
+	keyID, nonceStr, ciphertextStr, ok := parseLocalCiphertext(encrypted)
+	if !ok {
+		return "", errMalformedCiphertext
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(nonceStr)
+	if err != nil {
+		return "", errMalformedCiphertext
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextStr)
+	if err != nil {
+		return "", errMalformedCiphertext
+	}
+
+	key, ok := k.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("encryption key %q not found in keyring", keyID)
 	}
 
-	key, err := getEncryptionKey()
+	plaintext, err := openAESGCM(key, nonce, ciphertext)
 	if err != nil {
 		return "", err
 	}
 
+	return string(plaintext), nil
+}
+
+// parseLocalCiphertext splits a v1 or v2 ciphertext into the ID of its key and
+// its base64 encoded nonce and ciphertext. ok is false unless the value has the
+// full format, so plaintext that merely starts like a ciphertext is not mistaken for one.
+func parseLocalCiphertext(encrypted string) (keyID, nonce, ciphertext string, ok bool) {
 	parts := strings.Split(encrypted, ":")
-	// If not in encrypted format (v1:nonce:ciphertext), assume it's plain text
-	if len(parts) != 3 {
-		return encrypted, nil
+	switch {
+	case len(parts) == 3 && parts[0] == legacyKeyVersion:
+		keyID, nonce, ciphertext = legacyKeyID, parts[1], parts[2]
+	case len(parts) == 4 && parts[0] == keyVersion && parts[1] != "":
+		keyID, nonce, ciphertext = parts[1], parts[2], parts[3]
+	default:
+		return "", "", "", false
+	}
+
+	if !isSealedAESGCM(nonce, ciphertext) {
+		return "", "", "", false
+	}
+	return keyID, nonce, ciphertext, true
+}
+
+// EncryptionKeyID returns the ID of the key an encrypted value was sealed with,
+// or an empty string if the value is not encrypted
+func EncryptionKeyID(encrypted string) string {
+	keyID, _, _, _ := parseLocalCiphertext(encrypted)
+	return keyID
+}
+
+func ownsLocalCiphertext(encrypted string) bool {
+	return EncryptionKeyID(encrypted) != ""
+}
+
+// isSealedAESGCM reports whether nonce and ciphertext are base64 encoded output
+// of sealAESGCM: a standard size nonce and a ciphertext that holds at least the tag
+func isSealedAESGCM(nonce, ciphertext string) bool {
+	nonceBytes, err := base64.StdEncoding.DecodeString(nonce)
+	if err != nil || len(nonceBytes) != gcmNonceSize {
+		return false
+	}
+	ciphertextBytes, err := base64.StdEncoding.DecodeString(ciphertext)
+	return err == nil && len(ciphertextBytes) >= gcmTagSize
+}
+
+// sealAESGCM encrypts plaintext with a fresh random nonce
+func sealAESGCM(key, plaintext []byte) (nonce, ciphertext []byte, err error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	version := parts[0]
-	if version != keyVersion {
-		// If version doesn't match, might be plain text with colons
-		return encrypted, nil
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
-	nonce, err := base64.StdEncoding.DecodeString(parts[1])
+	return nonce, gcm.Seal(nil, nonce, plaintext, nil), nil
+}
+
+// openAESGCM decrypts and authenticates a ciphertext produced by sealAESGCM
+func openAESGCM(key, nonce, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
-		// Not valid base64, assume plain text
-		return encrypted, nil
+		return nil, err
 	}
 
-	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length: expected %d bytes, got %d", gcm.NonceSize(), len(nonce))
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
 	if err != nil {
-		// Not valid base64, assume plain text
-		return encrypted, nil
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
 	}
 
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
 
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
 
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		// Decryption failed, might be plain text that looks like encrypted format
-		return encrypted, nil
+	return gcm, nil
+}
+
+// loadKeyring builds the keyring from environment variables:
+//   - ENCRYPTION_KEY: the legacy single key, registered under the ID "default"
+//   - ENCRYPTION_KEYS: additional keys as comma-separated id:base64key pairs
+//   - ENCRYPTION_ACTIVE_KEY_ID: the key used for new encryptions
+//
+// If no active key ID is set, "default" is used when ENCRYPTION_KEY is present,
+// otherwise ENCRYPTION_KEYS must contain exactly one key.
+func loadKeyring() (*Keyring, error) {
+	keyring := &Keyring{keys: make(map[string][]byte)}
+
+	if keyStr := os.Getenv("ENCRYPTION_KEY"); keyStr != "" {
+		key, err := decodeKey("ENCRYPTION_KEY", keyStr)
+		if err != nil {
+			return nil, err
+		}
+		keyring.keys[legacyKeyID] = key
 	}
 
-	return string(plaintext), nil
-}
+	if keysStr := os.Getenv("ENCRYPTION_KEYS"); keysStr != "" {
+		for _, entry := range strings.Split(keysStr, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+
+			id, keyStr, ok := strings.Cut(entry, ":")
+			if !ok || id == "" {
+				return nil, errors.New("invalid ENCRYPTION_KEYS format: expected comma-separated id:base64key pairs")
+			}
+			if _, exists := keyring.keys[id]; exists {
+				return nil, fmt.Errorf("duplicate encryption key ID %q", id)
+			}
+
+			key, err := decodeKey(fmt.Sprintf("ENCRYPTION_KEYS[%s]", id), keyStr)
+			if err != nil {
+				return nil, err
+			}
+			keyring.keys[id] = key
+		}
+	}
 
-// getEncryptionKey retrieves and validates the encryption key from environment
-func getEncryptionKey() ([]byte, error) {
-	keyStr := os.Getenv("ENCRYPTION_KEY")
-	if keyStr == "" {
+	if len(keyring.keys) == 0 {
 		return nil, errors.New("ENCRYPTION_KEY environment variable not set")
 	}
 
+	keyring.activeID = os.Getenv("ENCRYPTION_ACTIVE_KEY_ID")
+	if keyring.activeID == "" {
+		if _, ok := keyring.keys[legacyKeyID]; ok {
+			keyring.activeID = legacyKeyID
+		} else if len(keyring.keys) == 1 {
+			for id := range keyring.keys {
+				keyring.activeID = id
+			}
+		} else {
+			return nil, errors.New("ENCRYPTION_ACTIVE_KEY_ID must be set when multiple keys are configured")
+		}
+	}
+
+	if _, ok := keyring.keys[keyring.activeID]; !ok {
+		return nil, fmt.Errorf("active encryption key %q not found in keyring", keyring.activeID)
+	}
+
+	return keyring, nil
+}
+
+// decodeKey decodes and validates a base64 encoded 32-byte key
+func decodeKey(name, keyStr string) ([]byte, error) {
 	key, err := base64.StdEncoding.DecodeString(keyStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid ENCRYPTION_KEY format (must be base64): %w", err)
+		return nil, fmt.Errorf("invalid %s format (must be base64): %w", name, err)
 	}
 
 	if len(key) != 32 {
-		return nil, fmt.Errorf("invalid ENCRYPTION_KEY length: expected 32 bytes, got %d", len(key))
+		return nil, fmt.Errorf("invalid %s length: expected 32 bytes, got %d", name, len(key))
 	}
 
 	return key, nil
 }
-
-// ValidateEncryptionKey validates the encryption key on startup
-func ValidateEncryptionKey() error {
-	_, err := getEncryptionKey()
-	return err
-}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var (
+	testLegacyKey = bytes.Repeat([]byte{1}, 32)
+	testNewKey    = bytes.Repeat([]byte{2}, 32)
+)
+
+// useSecretEnv sets the secret backend environment for a test and makes the
+// backends reload it
+func useSecretEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range []string{"SECRET_BACKEND", "ENCRYPTION_KEY", "ENCRYPTION_KEYS", "ENCRYPTION_ACTIVE_KEY_ID"} {
+		t.Setenv(name, env[name])
+	}
+
+	saved := secretStores
+	secretStores = newSecretStores()
+	t.Cleanup(func() { secretStores = saved })
+}
+
+func encodeTestKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// sealLegacy produces a v1 ciphertext as written before key rotation existed
+func sealLegacy(t *testing.T, key []byte, plaintext string) string {
+	t.Helper()
+	nonce, ciphertext, err := sealAESGCM(key, []byte(plaintext))
+	if err != nil {
+		t.Fatalf("sealAESGCM: %v", err)
+	}
+	return "v1:" + base64.StdEncoding.EncodeToString(nonce) + ":" + base64.StdEncoding.EncodeToString(ciphertext)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	cases := []struct {
+		name     string
+		env      map[string]string
+		activeID string
+		err      string // Empty if loading succeeds
+	}{
+		{
+			name:     "legacy key only",
+			env:      map[string]string{"ENCRYPTION_KEY": encodeTestKey(testLegacyKey)},
+			activeID: "default",
+		},
+		{
+			name:     "single additional key",
+			env:      map[string]string{"ENCRYPTION_KEYS": "2025-06:" + encodeTestKey(testNewKey)},
+			activeID: "2025-06",
+		},
+		{
+			name: "legacy key stays active until switched",
+			env: map[string]string{
+				"ENCRYPTION_KEY":  encodeTestKey(testLegacyKey),
+				"ENCRYPTION_KEYS": "2025-06:" + encodeTestKey(testNewKey),
+			},
+			activeID: "default",
+		},
+		{
+			name: "explicit active key",
+			env: map[string]string{
+				"ENCRYPTION_KEY":           encodeTestKey(testLegacyKey),
+				"ENCRYPTION_KEYS":          "2025-06:" + encodeTestKey(testNewKey),
+				"ENCRYPTION_ACTIVE_KEY_ID": "2025-06",
+			},
+			activeID: "2025-06",
+		},
+		{
+			name: "ambiguous active key",
+			env:  map[string]string{"ENCRYPTION_KEYS": "a:" + encodeTestKey(testLegacyKey) + ",b:" + encodeTestKey(testNewKey)},
+			err:  "ENCRYPTION_ACTIVE_KEY_ID must be set",
+		},
+		{
+			name: "unknown active key",
+			env: map[string]string{
+				"ENCRYPTION_KEY":           encodeTestKey(testLegacyKey),
+				"ENCRYPTION_ACTIVE_KEY_ID": "missing",
+			},
+			err: `active encryption key "missing" not found`,
+		},
+		{
+			name: "duplicate key ID",
+			env:  map[string]string{"ENCRYPTION_KEYS": "a:" + encodeTestKey(testLegacyKey) + ",a:" + encodeTestKey(testNewKey)},
+			err:  `duplicate encryption key ID "a"`,
+		},
+		{
+			name: "short key",
+			env:  map[string]string{"ENCRYPTION_KEY": encodeTestKey([]byte("short"))},
+			err:  "expected 32 bytes",
+		},
+		{
+			name: "no keys",
+			env:  map[string]string{},
+			err:  "ENCRYPTION_KEY environment variable not set",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			useSecretEnv(t, tc.env)
+
+			keyring, err := loadKeyring()
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("loadKeyring: err = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadKeyring: %v", err)
+			}
+			if keyring.ActiveKeyID() != tc.activeID {
+				t.Fatalf("active key = %q, want %q", keyring.ActiveKeyID(), tc.activeID)
+			}
+		})
+	}
+}
+
+func TestKeyringDecrypt(t *testing.T) {
+	keyring := &Keyring{
+		keys:     map[string][]byte{legacyKeyID: testLegacyKey, "2025-06": testNewKey},
+		activeID: "2025-06",
+	}
+	current, err := keyring.Encrypt("sk-current")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	unknown, err := (&Keyring{keys: map[string][]byte{"retired": testNewKey}, activeID: "retired"}).Encrypt("sk-unknown")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	legacy := sealLegacy(t, testLegacyKey, "sk-legacy")
+
+	cases := []struct {
+		name       string
+		ciphertext string
+		plaintext  string
+		keyID      string
+		current    bool
+		err        string // Empty if decryption succeeds
+	}{
+		{name: "active key", ciphertext: current, plaintext: "sk-current", keyID: "2025-06", current: true},
+		{name: "v1 legacy", ciphertext: legacy, plaintext: "sk-legacy", keyID: legacyKeyID},
+		{name: "unknown key ID", ciphertext: unknown, keyID: "retired", err: `encryption key "retired" not found in keyring`},
+		{name: "tampered", ciphertext: current[:len(current)-4] + "AAA=", keyID: "2025-06", current: true, err: "failed to decrypt"},
+		{name: "malformed", ciphertext: "v2:2025-06:abc", err: errMalformedCiphertext.Error()},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if id := EncryptionKeyID(tc.ciphertext); id != tc.keyID {
+				t.Errorf("EncryptionKeyID = %q, want %q", id, tc.keyID)
+			}
+			if keyring.IsCurrent(tc.ciphertext) != tc.current {
+				t.Errorf("IsCurrent = %v, want %v", !tc.current, tc.current)
+			}
+
+			plaintext, err := keyring.Decrypt(tc.ciphertext)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Decrypt: err = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if plaintext != tc.plaintext {
+				t.Fatalf("Decrypt = %q, want %q", plaintext, tc.plaintext)
+			}
+		})
+	}
+}
+
+func TestIsEncrypted(t *testing.T) {
+	local, err := (&Keyring{keys: map[string][]byte{"a": testNewKey}, activeID: "a"}).Encrypt("sk-secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	envelopeStore, err := NewEnvelopeStore(testNewKey)
+	if err != nil {
+		t.Fatalf("NewEnvelopeStore: %v", err)
+	}
+	envelope, err := envelopeStore.Encrypt("sk-secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	cases := []struct {
+		value string
+		want  bool
+	}{
+		{local, true},
+		{sealLegacy(t, testLegacyKey, "sk-secret"), true},
+		{envelope, true},
+		{"vault:v3:" + base64.StdEncoding.EncodeToString([]byte("ciphertext")), true},
+		// Plaintext keys that only look like ciphertexts
+		{"sk-proj-abc123", false},
+		{"v1:x:y", false},
+		{"v2:a:" + base64.StdEncoding.EncodeToString([]byte("short")) + ":" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0}, 16)), false},
+		{"e1:abc", false},
+		{"e1:zzzzzzzz:a:b:c:d", false},
+		{"vault:abc", false},
+		{"vault:v1:not base64!", false},
+	}
+	for _, tc := range cases {
+		if got := IsEncrypted(tc.value); got != tc.want {
+			t.Errorf("IsEncrypted(%q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestDecryptAPIKeyStrict(t *testing.T) {
+	useSecretEnv(t, map[string]string{"ENCRYPTION_KEY": encodeTestKey(testLegacyKey)})
+
+	t.Setenv("SECRET_STRICT_DECRYPT", "")
+	var decryptErr *DecryptionError
+	if _, err := DecryptAPIKey("v1:x:y"); !errors.As(err, &decryptErr) {
+		t.Fatalf("strict DecryptAPIKey of a plaintext key: err = %v, want a *DecryptionError", err)
+	}
+
+	t.Setenv("SECRET_STRICT_DECRYPT", "false")
+	if plaintext, err := DecryptAPIKey("v1:x:y"); err != nil || plaintext != "v1:x:y" {
+		t.Fatalf("lenient DecryptAPIKey = %q, %v, want the value as-is", plaintext, err)
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// envelopeVersion marks envelope-encrypted ciphertexts.
+// Format: e1:masterKeyID:wrapNonce:wrappedDataKey:nonce:ciphertext
+const envelopeVersion = "e1"
+
+// EnvelopeStore encrypts every secret with its own random data key and stores
+// that data key wrapped (AES-256-GCM) by a master key kept in a file. The master
+// key never leaves the server and only ever encrypts 32-byte data keys.
+type EnvelopeStore struct {
+	masterKeys  map[string][]byte
+	masterKeyID string
+}
+
+// NewEnvelopeStore creates an envelope store that wraps data keys with masterKey.
+// Retired master keys are only used to unwrap data keys of existing secrets.
+func NewEnvelopeStore(masterKey []byte, retiredKeys ...[]byte) (*EnvelopeStore, error) {
+	store := &EnvelopeStore{masterKeys: make(map[string][]byte)}
+
+	for i, key := range append([][]byte{masterKey}, retiredKeys...) {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("invalid master key length: expected 32 bytes, got %d", len(key))
+		}
+
+		id := masterKeyFingerprint(key)
+		store.masterKeys[id] = key
+		if i == 0 {
+			store.masterKeyID = id
+		}
+	}
+
+	return store, nil
+}
+
+// masterKeyFingerprint identifies a master key without revealing it
+func masterKeyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// loadEnvelopeStore reads the base64 encoded master key from SECRET_MASTER_KEY_FILE
+// and any retired master keys from the comma-separated SECRET_RETIRED_MASTER_KEY_FILES
+func loadEnvelopeStore() (*EnvelopeStore, error) {
+	path := os.Getenv("SECRET_MASTER_KEY_FILE")
+	if path == "" {
+		return nil, errors.New("SECRET_MASTER_KEY_FILE environment variable not set")
+	}
+
+	masterKey, err := readMasterKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var retiredKeys [][]byte
+	if paths := os.Getenv("SECRET_RETIRED_MASTER_KEY_FILES"); paths != "" {
+		for _, retiredPath := range strings.Split(paths, ",") {
+			retiredPath = strings.TrimSpace(retiredPath)
+			if retiredPath == "" {
+				continue
+			}
+
+			key, err := readMasterKeyFile(retiredPath)
+			if err != nil {
+				return nil, err
+			}
+			retiredKeys = append(retiredKeys, key)
+		}
+	}
+
+	return NewEnvelopeStore(masterKey, retiredKeys...)
+}
+
+func readMasterKeyFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+
+	return decodeKey("master key file "+path, strings.TrimSpace(string(content)))
+}
+
+// Name identifies the envelope backend
+func (s *EnvelopeStore) Name() string {
+	return SecretBackendEnvelope
+}
+
+// Encrypt encrypts a secret under a fresh data key and wraps the data key
+func (s *EnvelopeStore) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrapNonce, wrappedKey, err := sealAESGCM(s.masterKeys[s.masterKeyID], dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce, ciphertext, err := sealAESGCM(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		envelopeVersion,
+		s.masterKeyID,
+		base64.StdEncoding.EncodeToString(wrapNonce),
+		base64.StdEncoding.EncodeToString(wrappedKey),
+		base64.StdEncoding.EncodeToString(nonce),
+		base64.StdEncoding.EncodeToString(ciphertext),
+	}, ":"), nil
+}
+
+// Decrypt unwraps the data key with the master key and decrypts the secret
+func (s *EnvelopeStore) Decrypt(encrypted string) (string, error) {
+	parts := strings.Split(encrypted, ":")
+	if len(parts) != 6 || parts[0] != envelopeVersion {
+		return "", errMalformedCiphertext
+	}
+
+	masterKey, ok := s.masterKeys[parts[1]]
+	if !ok {
+		return "", fmt.Errorf("master key %q not found", parts[1])
+	}
+
+	decoded := make([][]byte, 4)
+	for i, part := range parts[2:] {
+		value, err := base64.StdEncoding.DecodeString(part)
+		if err != nil {
+			return "", errMalformedCiphertext
+		}
+		decoded[i] = value
+	}
+
+	dataKey, err := openAESGCM(masterKey, decoded[0], decoded[1])
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	plaintext, err := openAESGCM(dataKey, decoded[2], decoded[3])
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// Owns reports whether a value is an envelope ciphertext
+func (s *EnvelopeStore) Owns(encrypted string) bool {
+	return ownsEnvelopeCiphertext(encrypted)
+}
+
+// IsCurrent reports whether a ciphertext's data key is wrapped by the active master key
+func (s *EnvelopeStore) IsCurrent(encrypted string) bool {
+	parts := strings.Split(encrypted, ":")
+	return len(parts) == 6 && parts[0] == envelopeVersion && parts[1] == s.masterKeyID
+}
+
+// ownsEnvelopeCiphertext checks the full envelope format, so plaintext that
+// merely starts with the version prefix is not mistaken for a ciphertext
+func ownsEnvelopeCiphertext(encrypted string) bool {
+	parts := strings.Split(encrypted, ":")
+	if len(parts) != 6 || parts[0] != envelopeVersion {
+		return false
+	}
+	if id, err := hex.DecodeString(parts[1]); err != nil || len(id) != 4 {
+		return false
+	}
+	return isSealedAESGCM(parts[2], parts[3]) && isSealedAESGCM(parts[4], parts[5])
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"log"
+	"veritas-server/models"
+
+	"gorm.io/gorm"
+)
+
+// BrokenAPIKey describes a stored API key that cannot be decrypted
+type BrokenAPIKey struct {
+	ModelConfigID string `json:"modelConfigId"`
+	Name          string `json:"name"`
+	Error         string `json:"error"`
+}
+
+// CheckStoredAPIKeys attempts to decrypt every stored API key and returns the
+// configurations whose keys are broken. Plaintext keys are reported as broken
+// as well, regardless of whether strict decryption is enabled.
+func CheckStoredAPIKeys(db *gorm.DB) ([]BrokenAPIKey, error) {
+	var configs []models.ModelConfig
+	if err := db.Where("api_key IS NOT NULL AND api_key != ''").Find(&configs).Error; err != nil {
+		return nil, err
+	}
+
+	broken := []BrokenAPIKey{}
+	for _, config := range configs {
+		if _, err := decryptSecret(config.APIKey); err != nil {
+			broken = append(broken, BrokenAPIKey{
+				ModelConfigID: config.ID,
+				Name:          config.Name,
+				Error:         err.Error(),
+			})
+		}
+	}
+
+	return broken, nil
+}
+
+// LogStoredAPIKeyCheck runs CheckStoredAPIKeys and logs every broken configuration
+func LogStoredAPIKeyCheck(db *gorm.DB) {
+	broken, err := CheckStoredAPIKeys(db)
+	if err != nil {
+		log.Printf("Warning: Failed to check stored API keys: %v", err)
+		return
+	}
+
+	if len(broken) == 0 {
+		log.Println("All stored API keys decrypted successfully")
+		return
+	}
+
+	for _, b := range broken {
+		log.Printf("Warning: API key for model configuration %q (ID: %s) cannot be decrypted: %s", b.Name, b.ModelConfigID, b.Error)
+	}
+	log.Printf("Warning: %d model configurations have undecryptable API keys; check ENCRYPTION_KEY and the secret backend settings", len(broken))
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"veritas-server/models"
+
+	"gorm.io/gorm"
+)
+
+// KeyRotationResult summarizes a key rotation run
+type KeyRotationResult struct {
+	Backend     string `json:"backend"`
+	ActiveKeyID string `json:"activeKeyId,omitempty"`
+	Rotated     int    `json:"rotated"`
+	Unchanged   int    `json:"unchanged"`
+}
+
+// RotateAPIKeys re-encrypts every stored ModelConfig API key with the active
+// secret backend and its current key. Keys written by another backend are
+// migrated, and legacy plaintext keys are encrypted as well. Every key is
+// re-encrypted before any is written, so no backend calls happen while the
+// database transaction is open; if any key cannot be decrypted, nothing is
+// changed. A key that is changed while the rotation runs is left as it is.
+func RotateAPIKeys(db *gorm.DB) (*KeyRotationResult, error) {
+	active, err := ActiveSecretStore()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &KeyRotationResult{Backend: active.Name()}
+	if keyring, ok := active.(*Keyring); ok {
+		result.ActiveKeyID = keyring.ActiveKeyID()
+	}
+
+	var configs []models.ModelConfig
+	if err := db.Where("api_key IS NOT NULL AND api_key != ''").Find(&configs).Error; err != nil {
+		return nil, err
+	}
+
+	type rotation struct {
+		config    *models.ModelConfig
+		encrypted string
+	}
+	var rotations []rotation
+	for i := range configs {
+		config := &configs[i]
+		encrypted, changed, err := reencryptAPIKey(active, config.APIKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rotate API key for %q (ID: %s): %w", config.Name, config.ID, err)
+		}
+		if !changed {
+			result.Unchanged++
+			continue
+		}
+		rotations = append(rotations, rotation{config: config, encrypted: encrypted})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, r := range rotations {
+			// UpdateColumn keeps updated_at untouched: the configuration itself did not change
+			update := tx.Model(&models.ModelConfig{}).
+				Where("id = ? AND api_key = ?", r.config.ID, r.config.APIKey).
+				UpdateColumn("api_key", r.encrypted)
+			if update.Error != nil {
+				return update.Error
+			}
+			if update.RowsAffected == 0 {
+				log.Printf("API key for %q (ID: %s) changed during rotation, leaving it as it is", r.config.Name, r.config.ID)
+				continue
+			}
+			result.Rotated++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Rotated %d API keys with secret backend %q (%d already current)", result.Rotated, result.Backend, result.Unchanged)
+	return result, nil
+}
+
+// reencryptAPIKey re-encrypts a stored API key, or a legacy plaintext key, with
+// the active backend's current key. changed is false if it already is.
+func reencryptAPIKey(active SecretStore, stored string) (encrypted string, changed bool, err error) {
+	if active.Owns(stored) && active.IsCurrent(stored) {
+		return stored, false, nil
+	}
+
+	plaintext := stored
+	owner, err := secretStoreFor(stored)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to load secret backend: %w", err)
+	}
+	if owner != nil {
+		if plaintext, err = owner.Decrypt(stored); err != nil {
+			return "", false, fmt.Errorf("failed to decrypt: %w", err)
+		}
+	}
+
+	encrypted, err = active.Encrypt(plaintext)
+	if err != nil {
+		return "", false, err
+	}
+	return encrypted, true, nil
+}
@@ -0,0 +1,50 @@
+package services
+
+import "testing"
+
+func TestReencryptAPIKeyTwice(t *testing.T) {
+	useSecretEnv(t, map[string]string{
+		"ENCRYPTION_KEY":           encodeTestKey(testLegacyKey),
+		"ENCRYPTION_KEYS":          "2025-06:" + encodeTestKey(testNewKey),
+		"ENCRYPTION_ACTIVE_KEY_ID": "2025-06",
+	})
+	active, err := ActiveSecretStore()
+	if err != nil {
+		t.Fatalf("ActiveSecretStore: %v", err)
+	}
+
+	for _, stored := range []string{sealLegacy(t, testLegacyKey, "sk-secret"), "sk-secret"} {
+		rotated, changed, err := reencryptAPIKey(active, stored)
+		if err != nil {
+			t.Fatalf("first rotation of %q: %v", stored, err)
+		}
+		if !changed || EncryptionKeyID(rotated) != "2025-06" {
+			t.Fatalf("first rotation of %q = %q, changed %v; want a ciphertext of the active key", stored, rotated, changed)
+		}
+
+		again, changed, err := reencryptAPIKey(active, rotated)
+		if err != nil {
+			t.Fatalf("second rotation: %v", err)
+		}
+		if changed || again != rotated {
+			t.Fatalf("second rotation changed the key: %q -> %q", rotated, again)
+		}
+
+		plaintext, err := DecryptAPIKey(again)
+		if err != nil || plaintext != "sk-secret" {
+			t.Fatalf("DecryptAPIKey after rotation = %q, %v", plaintext, err)
+		}
+	}
+}
+
+func TestReencryptAPIKeyUnknownKey(t *testing.T) {
+	useSecretEnv(t, map[string]string{"ENCRYPTION_KEYS": "2025-06:" + encodeTestKey(testNewKey)})
+	active, err := ActiveSecretStore()
+	if err != nil {
+		t.Fatalf("ActiveSecretStore: %v", err)
+	}
+
+	if _, _, err := reencryptAPIKey(active, sealLegacy(t, testLegacyKey, "sk-secret")); err == nil {
+		t.Fatalf("rotating a key encrypted with a key missing from the keyring succeeded")
+	}
+}
@@ -66,3 +66,49 @@ func MigrateDefaultModelConfig(db *gorm.DB) error {
 	log.Printf("Default model configuration created: %s (ID: %s)", config.Name, config.ID)
 	return nil
 }
+
+// EncryptPlaintextAPIKeys encrypts any API keys that are still stored in plain text,
+// e.g. keys written before encryption was introduced. Safe to run on every startup:
+// once all keys are encrypted it does nothing.
+func EncryptPlaintextAPIKeys(db *gorm.DB) error {
+	var configs []models.ModelConfig
+	if err := db.Where("api_key IS NOT NULL AND api_key != ''").Find(&configs).Error; err != nil {
+		return err
+	}
+
+	var plaintextConfigs []models.ModelConfig
+	for _, config := range configs {
+		if !IsEncrypted(config.APIKey) {
+			plaintextConfigs = append(plaintextConfigs, config)
+		}
+	}
+
+	if len(plaintextConfigs) == 0 {
+		return nil
+	}
+
+	// Encrypt before opening the transaction, the backend may be a remote service
+	encryptedKeys := make([]string, len(plaintextConfigs))
+	for i, config := range plaintextConfigs {
+		encryptedKey, err := EncryptAPIKey(config.APIKey)
+		if err != nil {
+			return err
+		}
+		encryptedKeys[i] = encryptedKey
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i, config := range plaintextConfigs {
+			if err := tx.Model(&config).UpdateColumn("api_key", encryptedKeys[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Encrypted %d plaintext API keys", len(plaintextConfigs))
+	return nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"veritas-server/models"
+)
+
+//go:embed model_catalog.json
+var modelCatalogJSON []byte
+
+// ModelPricing is a model's list price in USD per million tokens
+type ModelPricing struct {
+	InputPerMillion  float64 `json:"inputPerMillion"`
+	OutputPerMillion float64 `json:"outputPerMillion"`
+}
+
+// ModelCatalogEntry describes a well-known model
+type ModelCatalogEntry struct {
+	ID              string        `json:"id"`
+	Name            string        `json:"name"`
+	Provider        string        `json:"provider"`
+	Description     string        `json:"description"`
+	ContextWindow   int           `json:"contextWindow"`
+	MaxOutputTokens int           `json:"maxOutputTokens"` // 0 if the model has no separate output limit
+	ToolCalling     bool          `json:"toolCalling"`
+	Vision          bool          `json:"vision"`
+	JSONMode        bool          `json:"jsonMode"`
+	Pricing         *ModelPricing `json:"pricing"` // Nil for local models
+}
+
+// ModelInfo is what is known about a configured model: its catalog entry with
+// the configuration's overrides applied. Fields are nil when unknown, except
+// Vision, which always reflects the configuration's vision capability.
+type ModelInfo struct {
+	CatalogID       string        `json:"catalogId,omitempty"` // Matching catalog entry, empty if none
+	DisplayName     string        `json:"displayName,omitempty"`
+	Description     string        `json:"description,omitempty"`
+	ContextWindow   *int          `json:"contextWindow"`
+	MaxOutputTokens *int          `json:"maxOutputTokens"`
+	ToolCalling     *bool         `json:"toolCalling"`
+	Vision          *bool         `json:"vision"`
+	JSONMode        *bool         `json:"jsonMode"`
+	Pricing         *ModelPricing `json:"pricing"`
+}
+
+// SupportsToolCalling reports whether tools may be offered to the model;
+// models not in the catalog are assumed to support them
+func (m ModelInfo) SupportsToolCalling() bool {
+	return m.ToolCalling == nil || *m.ToolCalling
+}
+
+var loadModelCatalog = sync.OnceValue(func() []ModelCatalogEntry {
+	var catalog []ModelCatalogEntry
+	if err := json.Unmarshal(modelCatalogJSON, &catalog); err != nil {
+		log.Printf("Warning: Failed to parse the embedded model catalog: %v", err)
+	}
+	return catalog
+})
+
+// ModelCatalog returns every catalog entry
+func ModelCatalog() []ModelCatalogEntry {
+	return loadModelCatalog()
+}
+
+// LookupModel finds the catalog entry for a provider's model ID. Provider
+// prefixes ("openai/gpt-4o"), Ollama tags ("llama3.1:8b") and dated or
+// suffixed versions ("gpt-4o-2024-08-06") match their base entry; the longest
+// matching entry wins, so "gpt-4o-mini-2024-07-18" is GPT-4o Mini.
+func LookupModel(modelID string) (*ModelCatalogEntry, bool) {
+	id := strings.ToLower(strings.TrimSpace(modelID))
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+	id, _, _ = strings.Cut(id, ":")
+
+	catalog := loadModelCatalog()
+	var best *ModelCatalogEntry
+	for i := range catalog {
+		entry := &catalog[i]
+		if id == entry.ID {
+			return entry, true
+		}
+		if strings.HasPrefix(id, entry.ID+"-") && (best == nil || len(entry.ID) > len(best.ID)) {
+			best = entry
+		}
+	}
+	return best, best != nil
+}
+
+// ResolveModelInfo combines the catalog entry of a configuration's model with
+// the overrides stored on the configuration
+func ResolveModelInfo(config *models.ModelConfig) ModelInfo {
+	var info ModelInfo
+	if entry, ok := LookupModel(config.ModelID); ok {
+		info = ModelInfo{
+			CatalogID:     entry.ID,
+			DisplayName:   entry.Name,
+			Description:   entry.Description,
+			ContextWindow: &entry.ContextWindow,
+			ToolCalling:   &entry.ToolCalling,
+			JSONMode:      &entry.JSONMode,
+		}
+		if entry.MaxOutputTokens > 0 {
+			info.MaxOutputTokens = &entry.MaxOutputTokens
+		}
+		if entry.Pricing != nil {
+			pricing := *entry.Pricing
+			info.Pricing = &pricing
+		}
+	}
+
+	// Image validation and the chat path go by the capability, so report the same
+	vision := config.HasCapability(models.CapabilityVision)
+	info.Vision = &vision
+
+	overrides := config.CatalogOverrides
+	if overrides == nil {
+		return info
+	}
+	if overrides.ContextWindow != nil {
+		info.ContextWindow = overrides.ContextWindow
+	}
+	if overrides.MaxOutputTokens != nil {
+		info.MaxOutputTokens = overrides.MaxOutputTokens
+	}
+	if overrides.ToolCalling != nil {
+		info.ToolCalling = overrides.ToolCalling
+	}
+	if overrides.JSONMode != nil {
+		info.JSONMode = overrides.JSONMode
+	}
+	if overrides.InputPricePerMillion != nil || overrides.OutputPricePerMillion != nil {
+		pricing := ModelPricing{}
+		if info.Pricing != nil {
+			pricing = *info.Pricing
+		}
+		if overrides.InputPricePerMillion != nil {
+			pricing.InputPerMillion = *overrides.InputPricePerMillion
+		}
+		if overrides.OutputPricePerMillion != nil {
+			pricing.OutputPerMillion = *overrides.OutputPricePerMillion
+		}
+		info.Pricing = &pricing
+	}
+	return info
+}
+
+// DefaultCapabilities returns the capabilities of a new configuration for a
+// model: chat, plus vision if the catalog lists the model as reading images
+func DefaultCapabilities(modelID string) []string {
+	capabilities := []string{models.CapabilityChat}
+	if entry, ok := LookupModel(modelID); ok && entry.Vision {
+		capabilities = append(capabilities, models.CapabilityVision)
+	}
+	return capabilities
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Supported values for the SECRET_BACKEND environment variable
+const (
+	SecretBackendLocal    = "local"
+	SecretBackendEnvelope = "envelope"
+	SecretBackendVault    = "vault"
+)
+
+var (
+	// errMalformedCiphertext is returned when a value claimed by a backend cannot be parsed
+	errMalformedCiphertext = errors.New("malformed ciphertext")
+	// errNotEncrypted is returned in strict mode for values in no known encrypted format
+	errNotEncrypted = errors.New("value is not encrypted")
+)
+
+// DecryptionError reports a stored secret that could not be decrypted, typically
+// because the configured encryption key differs from the one used to encrypt it
+type DecryptionError struct {
+	Backend string
+	Err     error
+}
+
+func (e *DecryptionError) Error() string {
+	if e.Backend == "" {
+		return fmt.Sprintf("failed to decrypt secret: %v", e.Err)
+	}
+	return fmt.Sprintf("failed to decrypt secret with %s backend: %v", e.Backend, e.Err)
+}
+
+func (e *DecryptionError) Unwrap() error {
+	return e.Err
+}
+
+// SecretStore encrypts and decrypts secrets such as provider API keys.
+// Each backend produces ciphertexts with a distinct prefix so that values
+// written by one backend can still be decrypted after switching to another.
+type SecretStore interface {
+	// Name returns the backend name as used in SECRET_BACKEND
+	Name() string
+	// Encrypt encrypts a plaintext secret
+	Encrypt(plaintext string) (string, error)
+	// Decrypt decrypts a ciphertext produced by this backend
+	Decrypt(ciphertext string) (string, error)
+	// Owns reports whether a value is in this backend's ciphertext format
+	Owns(ciphertext string) bool
+	// IsCurrent reports whether a ciphertext is encrypted with this backend's current key
+	IsCurrent(ciphertext string) bool
+}
+
+// EncryptAPIKey encrypts an API key with the configured secret backend
+func EncryptAPIKey(plaintext string) (string, error) {
+	store, err := ActiveSecretStore()
+	if err != nil {
+		return "", err
+	}
+
+	return store.Encrypt(plaintext)
+}
+
+// DecryptAPIKey decrypts an encrypted API key with the backend that produced it.
+// In strict mode (the default) a value that cannot be decrypted, or that is not
+// encrypted at all, returns a *DecryptionError. With SECRET_STRICT_DECRYPT=false
+// such values are returned as-is, the legacy behavior for plaintext keys.
+func DecryptAPIKey(encrypted string) (string, error) {
+	// If empty, return empty
+	if encrypted == "" {
+		return "", nil
+	}
+
+	plaintext, err := decryptSecret(encrypted)
+	if err != nil {
+		if StrictDecryption() {
+			return "", err
+		}
+		if !errors.Is(err, errNotEncrypted) {
+			log.Printf("Warning: using undecryptable API key as-is because strict decryption is disabled: %v", err)
+		}
+		return encrypted, nil
+	}
+
+	return plaintext, nil
+}
+
+// decryptSecret decrypts a value with the backend that owns it
+func decryptSecret(encrypted string) (string, error) {
+	store, err := secretStoreFor(encrypted)
+	if err != nil {
+		return "", &DecryptionError{Err: err}
+	}
+	if store == nil {
+		return "", &DecryptionError{Err: errNotEncrypted}
+	}
+
+	plaintext, err := store.Decrypt(encrypted)
+	if err != nil {
+		return "", &DecryptionError{Backend: store.Name(), Err: err}
+	}
+
+	return plaintext, nil
+}
+
+// IsEncrypted reports whether a value is in any known secret backend's format
+func IsEncrypted(value string) bool {
+	return ownsLocalCiphertext(value) || ownsEnvelopeCiphertext(value) || ownsVaultCiphertext(value)
+}
+
+// StrictDecryption reports whether undecryptable secrets are rejected.
+// Enabled unless SECRET_STRICT_DECRYPT is set to a false value.
+func StrictDecryption() bool {
+	value := os.Getenv("SECRET_STRICT_DECRYPT")
+	if value == "" {
+		return true
+	}
+
+	strict, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid SECRET_STRICT_DECRYPT value %q, using strict decryption", value)
+		return true
+	}
+	return strict
+}
+
+// ActiveSecretStore returns the backend selected by SECRET_BACKEND (default: local)
+func ActiveSecretStore() (SecretStore, error) {
+	backend := os.Getenv("SECRET_BACKEND")
+	if backend == "" {
+		backend = SecretBackendLocal
+	}
+
+	return newSecretStore(backend)
+}
+
+// secretStoreFor returns the backend that owns a ciphertext, or nil if the
+// value is not in any known encrypted format
+func secretStoreFor(ciphertext string) (SecretStore, error) {
+	switch {
+	case ownsLocalCiphertext(ciphertext):
+		return newSecretStore(SecretBackendLocal)
+	case ownsEnvelopeCiphertext(ciphertext):
+		return newSecretStore(SecretBackendEnvelope)
+	case ownsVaultCiphertext(ciphertext):
+		return newSecretStore(SecretBackendVault)
+	default:
+		return nil, nil
+	}
+}
+
+// secretStores builds each backend once from its environment configuration,
+// so a backend keeps its state, such as Vault's key version cache, between calls
+var secretStores = newSecretStores()
+
+// newSecretStores returns loaders for every backend that have not run yet
+func newSecretStores() map[string]func() (SecretStore, error) {
+	return map[string]func() (SecretStore, error){
+		SecretBackendLocal:    loadOnce(loadKeyring),
+		SecretBackendEnvelope: loadOnce(loadEnvelopeStore),
+		SecretBackendVault:    loadOnce(loadVaultTransitStore),
+	}
+}
+
+// loadOnce wraps a backend loader so that it runs at most once
+func loadOnce[T SecretStore](load func() (T, error)) func() (SecretStore, error) {
+	return sync.OnceValues(func() (SecretStore, error) {
+		store, err := load()
+		if err != nil {
+			// Return an untyped nil rather than a nil *T in the interface
+			return nil, err
+		}
+		return store, nil
+	})
+}
+
+// newSecretStore returns a backend, built from its environment configuration on first use
+func newSecretStore(backend string) (SecretStore, error) {
+	load, ok := secretStores[backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown SECRET_BACKEND %q (expected %s, %s or %s)",
+			backend, SecretBackendLocal, SecretBackendEnvelope, SecretBackendVault)
+	}
+	return load()
+}
+
+// ValidateEncryptionKey validates the configured secret backend on startup
+func ValidateEncryptionKey() error {
+	_, err := ActiveSecretStore()
+	return err
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vaultCiphertextPrefix is the prefix Vault's transit engine puts on every ciphertext
+const vaultCiphertextPrefix = "vault:"
+
+// vaultKeyVersionTTL is how long the transit key's latest version is cached
+const vaultKeyVersionTTL = time.Minute
+
+// VaultTransitStore encrypts secrets through a HashiCorp Vault compatible
+// transit secrets engine, so key material never reaches the Veritas server
+type VaultTransitStore struct {
+	addr      string
+	token     string
+	namespace string
+	mount     string
+	keyName   string
+	client    *http.Client
+
+	mu              sync.Mutex
+	latestVersion   int
+	latestFetchedAt time.Time
+}
+
+// NewVaultTransitStore creates a transit store for the given Vault address and key.
+// A custom HTTP client can be passed, e.g. to talk to a local fake; nil uses a default client.
+func NewVaultTransitStore(addr, token, mount, keyName string, client *http.Client) (*VaultTransitStore, error) {
+	if addr == "" {
+		return nil, errors.New("vault address not set")
+	}
+	if token == "" {
+		return nil, errors.New("vault token not set")
+	}
+	if mount == "" {
+		mount = "transit"
+	}
+	if keyName == "" {
+		return nil, errors.New("vault transit key name not set")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &VaultTransitStore{
+		addr:    strings.TrimRight(addr, "/"),
+		token:   token,
+		mount:   strings.Trim(mount, "/"),
+		keyName: keyName,
+		client:  client,
+	}, nil
+}
+
+// loadVaultTransitStore configures the transit store from VAULT_ADDR, VAULT_TOKEN,
+// VAULT_NAMESPACE, VAULT_TRANSIT_MOUNT (default: transit) and VAULT_TRANSIT_KEY
+// (default: veritas)
+func loadVaultTransitStore() (*VaultTransitStore, error) {
+	keyName := os.Getenv("VAULT_TRANSIT_KEY")
+	if keyName == "" {
+		keyName = "veritas"
+	}
+
+	store, err := NewVaultTransitStore(
+		os.Getenv("VAULT_ADDR"),
+		os.Getenv("VAULT_TOKEN"),
+		os.Getenv("VAULT_TRANSIT_MOUNT"),
+		keyName,
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vault configuration: %w", err)
+	}
+
+	store.namespace = os.Getenv("VAULT_NAMESPACE")
+	return store, nil
+}
+
+// Name identifies the vault backend
+func (s *VaultTransitStore) Name() string {
+	return SecretBackendVault
+}
+
+// Encrypt encrypts a secret with the transit key
+func (s *VaultTransitStore) Encrypt(plaintext string) (string, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+
+	err := s.call(http.MethodPost, "encrypt", map[string]string{
+		"plaintext": base64.StdEncoding.EncodeToString([]byte(plaintext)),
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+
+	if !ownsVaultCiphertext(resp.Data.Ciphertext) {
+		return "", errors.New("vault returned an invalid ciphertext")
+	}
+
+	return resp.Data.Ciphertext, nil
+}
+
+// Decrypt decrypts a transit ciphertext
+func (s *VaultTransitStore) Decrypt(encrypted string) (string, error) {
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+
+	if err := s.call(http.MethodPost, "decrypt", map[string]string{"ciphertext": encrypted}, &resp); err != nil {
+		return "", err
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return "", fmt.Errorf("vault returned invalid plaintext encoding: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// Owns reports whether a value is a transit ciphertext
+func (s *VaultTransitStore) Owns(encrypted string) bool {
+	return ownsVaultCiphertext(encrypted)
+}
+
+// IsCurrent reports whether a ciphertext ("vault:vN:...") was encrypted with
+// the transit key's latest version. If the latest version cannot be read, the
+// ciphertext is treated as outdated so rotation re-encrypts it.
+func (s *VaultTransitStore) IsCurrent(encrypted string) bool {
+	version, ok := vaultCiphertextVersion(encrypted)
+	if !ok {
+		return false
+	}
+
+	latest, err := s.latestKeyVersion()
+	if err != nil {
+		log.Printf("Failed to read vault transit key version: %v", err)
+		return false
+	}
+	return version == latest
+}
+
+// latestKeyVersion returns the transit key's latest version, cached for vaultKeyVersionTTL
+func (s *VaultTransitStore) latestKeyVersion() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.latestVersion > 0 && time.Since(s.latestFetchedAt) < vaultKeyVersionTTL {
+		return s.latestVersion, nil
+	}
+
+	var resp struct {
+		Data struct {
+			LatestVersion int `json:"latest_version"`
+		} `json:"data"`
+	}
+	if err := s.call(http.MethodGet, "keys", nil, &resp); err != nil {
+		return 0, err
+	}
+	if resp.Data.LatestVersion < 1 {
+		return 0, errors.New("vault returned no latest key version")
+	}
+
+	s.latestVersion = resp.Data.LatestVersion
+	s.latestFetchedAt = time.Now()
+	return s.latestVersion, nil
+}
+
+// call sends a request to a transit endpoint (encrypt, decrypt or keys) for the
+// store's key and decodes the response. A nil body sends no request body.
+func (s *VaultTransitStore) call(method, operation string, body any, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s", s.addr, s.mount, operation, url.PathEscape(s.keyName))
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create vault request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", s.token)
+	if s.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.namespace)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault %s request failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+		return fmt.Errorf("vault %s failed with status %d: %s", operation, resp.StatusCode, strings.Join(errResp.Errors, "; "))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode vault %s response: %w", operation, err)
+	}
+
+	return nil
+}
+
+// ownsVaultCiphertext checks the full "vault:vN:base64" format, so plaintext
+// that merely starts with the prefix is not mistaken for a ciphertext
+func ownsVaultCiphertext(encrypted string) bool {
+	if _, ok := vaultCiphertextVersion(encrypted); !ok {
+		return false
+	}
+	parts := strings.Split(encrypted, ":")
+	if len(parts) != 3 || parts[2] == "" {
+		return false
+	}
+	_, err := base64.StdEncoding.DecodeString(parts[2])
+	return err == nil
+}
+
+// vaultCiphertextVersion returns the key version N of a "vault:vN:..." ciphertext
+func vaultCiphertextVersion(encrypted string) (int, bool) {
+	rest, ok := strings.CutPrefix(encrypted, vaultCiphertextPrefix+"v")
+	if !ok {
+		return 0, false
+	}
+	digits, _, ok := strings.Cut(rest, ":")
+	if !ok {
+		return 0, false
+	}
+	version, err := strconv.Atoi(digits)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
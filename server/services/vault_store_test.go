@@ -0,0 +1,159 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeTransit is a minimal in-memory stand-in for Vault's transit engine
+type fakeTransit struct {
+	mu            sync.Mutex
+	token         string
+	latestVersion int
+	keyReads      int
+}
+
+func (f *fakeTransit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fail := func(status int, message string) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string][]string{"errors": {message}})
+	}
+	respond := func(data map[string]any) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}
+
+	if r.Header.Get("X-Vault-Token") != f.token {
+		fail(http.StatusForbidden, "permission denied")
+		return
+	}
+
+	var body map[string]string
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			fail(http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/transit/encrypt/veritas":
+		// Not real encryption; only the format matters to the store
+		respond(map[string]any{"ciphertext": fmt.Sprintf("vault:v%d:%s", f.latestVersion, body["plaintext"])})
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/transit/decrypt/veritas":
+		version, ok := vaultCiphertextVersion(body["ciphertext"])
+		if !ok || version > f.latestVersion {
+			fail(http.StatusBadRequest, "invalid ciphertext")
+			return
+		}
+		prefix := fmt.Sprintf("vault:v%d:", version)
+		respond(map[string]any{"plaintext": strings.TrimPrefix(body["ciphertext"], prefix)})
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/transit/keys/veritas":
+		f.keyReads++
+		respond(map[string]any{"latest_version": f.latestVersion})
+	default:
+		fail(http.StatusNotFound, "unsupported path")
+	}
+}
+
+func newFakeVaultStore(t *testing.T, token string) (*VaultTransitStore, *fakeTransit) {
+	t.Helper()
+	fake := &fakeTransit{token: "test-token", latestVersion: 1}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewVaultTransitStore(server.URL, token, "", "veritas", server.Client())
+	if err != nil {
+		t.Fatalf("NewVaultTransitStore: %v", err)
+	}
+	return store, fake
+}
+
+func TestVaultTransitStoreRoundTrip(t *testing.T) {
+	store, _ := newFakeVaultStore(t, "test-token")
+
+	encrypted, err := store.Encrypt("sk-secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !store.Owns(encrypted) {
+		t.Fatalf("store does not own its ciphertext %q", encrypted)
+	}
+	if encrypted != "vault:v1:"+base64.StdEncoding.EncodeToString([]byte("sk-secret")) {
+		t.Fatalf("unexpected ciphertext %q", encrypted)
+	}
+
+	plaintext, err := store.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if plaintext != "sk-secret" {
+		t.Fatalf("Decrypt = %q, want %q", plaintext, "sk-secret")
+	}
+}
+
+func TestVaultTransitStoreIsCurrent(t *testing.T) {
+	store, fake := newFakeVaultStore(t, "test-token")
+
+	encrypted, err := store.Encrypt("sk-secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !store.IsCurrent(encrypted) {
+		t.Fatalf("ciphertext of the latest key version is not current")
+	}
+	store.IsCurrent(encrypted)
+	if fake.keyReads != 1 {
+		t.Fatalf("latest key version was read %d times, want 1 (cached)", fake.keyReads)
+	}
+
+	// After the key is rotated in Vault, older ciphertexts are outdated
+	fake.latestVersion = 2
+	store.latestFetchedAt = store.latestFetchedAt.Add(-vaultKeyVersionTTL)
+	if store.IsCurrent(encrypted) {
+		t.Fatalf("ciphertext of an old key version is current")
+	}
+
+	for _, value := range []string{"vault:", "vault:vx:abc", "vault:v2", "local:v1:abc"} {
+		if store.IsCurrent(value) {
+			t.Errorf("IsCurrent(%q) = true for a malformed ciphertext", value)
+		}
+	}
+}
+
+func TestVaultTransitStoreErrors(t *testing.T) {
+	store, _ := newFakeVaultStore(t, "wrong-token")
+
+	if _, err := store.Encrypt("sk-secret"); err == nil || !strings.Contains(err.Error(), "status 403: permission denied") {
+		t.Fatalf("Encrypt with a wrong token: err = %v", err)
+	}
+	if store.IsCurrent("vault:v1:abc") {
+		t.Fatalf("IsCurrent = true when the key version cannot be read")
+	}
+
+	store, _ = newFakeVaultStore(t, "test-token")
+	if _, err := store.Decrypt("vault:v9:abc"); err == nil || !strings.Contains(err.Error(), "invalid ciphertext") {
+		t.Fatalf("Decrypt of an unknown key version: err = %v", err)
+	}
+}
+
+func TestNewVaultTransitStoreValidation(t *testing.T) {
+	cases := []struct{ addr, token, key string }{
+		{"", "token", "veritas"},
+		{"http://vault", "", "veritas"},
+		{"http://vault", "token", ""},
+	}
+	for _, tc := range cases {
+		if _, err := NewVaultTransitStore(tc.addr, tc.token, "", tc.key, nil); err == nil {
+			t.Errorf("NewVaultTransitStore(%q, %q, %q) succeeded", tc.addr, tc.token, tc.key)
+		}
+	}
+}
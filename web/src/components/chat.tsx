@@ -11,6 +11,7 @@ interface Message {
   role: 'user' | 'assistant';
   content: string;
   modelConfigId?: string;
+  modelName?: string;
 }
 
 interface Conversation {
@@ -27,6 +28,7 @@ interface ModelConfig {
   baseUrl: string;
   modelId: string;
   isDefault: boolean;
+  capabilities?: string[];
   createdAt: string;
   updatedAt: string;
 }
@@ -49,7 +51,7 @@ export function Chat() {
   const fetchConversations = useCallback(() => {
     fetch('http://localhost:8080/api/conversations')
       .then((res) => res.json())
-      .then((data) => setConversations(data || []))
+      .then((data) => setConversations(data.items || []))
       .catch((err) => console.error('Failed to fetch conversations:', err));
   }, []);
 
@@ -57,12 +59,14 @@ export function Chat() {
     // Fetch model configs
     fetch('http://localhost:8080/api/model-configs')
       .then((res) => res.json())
-      .then((data) => {
-        setModelConfigs(data);
-        if (data.length > 0) {
+      .then((data: ModelConfig[]) => {
+        // Only chat models can answer messages
+        const chatModels = data.filter((m) => !m.capabilities || m.capabilities.includes('chat'));
+        setModelConfigs(chatModels);
+        if (chatModels.length > 0) {
           // Select default model or first model
-          const defaultModel = data.find((m: ModelConfig) => m.isDefault);
-          setSelectedModelConfigId(defaultModel?.id || data[0].id);
+          const defaultModel = chatModels.find((m) => m.isDefault);
+          setSelectedModelConfigId(defaultModel?.id || chatModels[0].id);
         }
       })
       .catch((err) => console.error('Failed to fetch model configs:', err));
@@ -229,9 +233,9 @@ export function Chat() {
                 >
                   {msg.content}
                 </div>
-                {msg.role === 'assistant' && msg.modelConfigId && (
+                {msg.role === 'assistant' && (msg.modelName || msg.modelConfigId) && (
                   <div className="text-muted-foreground text-xs">
-                    {getModelName(msg.modelConfigId)}
+                    {msg.modelName || getModelName(msg.modelConfigId)}
                   </div>
                 )}
               </div>
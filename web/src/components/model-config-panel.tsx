@@ -14,6 +14,9 @@ interface ModelConfig {
   baseUrl: string;
   modelId: string;
   isDefault: boolean;
+  lastTestedAt?: string | null;
+  lastTestSuccess?: boolean;
+  lastTestMessage?: string;
   createdAt: string;
   updatedAt: string;
 }
@@ -27,6 +30,23 @@ interface ModelConfigFormData {
   isDefault: boolean;
 }
 
+interface DiagnosticCheck {
+  name: string;
+  supported: boolean;
+  latencyMs: number;
+  errorClass?: string;
+  error?: string;
+}
+
+const checkLabels: Record<string, string> = {
+  chat: 'Chat completion',
+  embedding: 'Embedding',
+  modelLookup: 'Model lookup',
+  streaming: 'Streaming',
+  toolCalling: 'Tool calling',
+  jsonMode: 'JSON mode',
+};
+
 export function ModelConfigPanel() {
   const [configs, setConfigs] = useState<ModelConfig[]>([]);
   const [loading, setLoading] = useState(false);
@@ -36,6 +56,8 @@ export function ModelConfigPanel() {
   const [testResult, setTestResult] = useState<{
     success: boolean;
     message: string;
+    errorDetails?: string;
+    checks?: DiagnosticCheck[];
   } | null>(null);
   const [formData, setFormData] = useState<ModelConfigFormData>({
     name: '',
@@ -46,6 +68,8 @@ export function ModelConfigPanel() {
     isDefault: false,
   });
   const [error, setError] = useState<string | null>(null);
+  const [discovering, setDiscovering] = useState(false);
+  const [discoveredModels, setDiscoveredModels] = useState<string[]>([]);
 
   const fetchConfigs = useCallback(async () => {
     try {
@@ -94,7 +118,11 @@ export function ModelConfigPanel() {
   };
 
   const handleDelete = async (id: string) => {
-    if (!confirm('Are you sure you want to delete this configuration?')) {
+    if (
+      !confirm(
+        'Are you sure you want to delete this configuration? Existing conversations will keep showing its name.'
+      )
+    ) {
       return;
     }
 
@@ -133,18 +161,27 @@ export function ModelConfigPanel() {
     setError(null);
 
     try {
-      const res = await fetch('http://localhost:8080/api/model-configs/test', {
-        method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({
-          baseUrl: formData.baseUrl,
-          modelId: formData.modelId,
-          apiKey: formData.apiKey,
-        }),
-      });
+      // Without a new key, test the saved configuration with its stored key
+      const savedTest = editingId !== null && formData.apiKey === '';
+      const res = savedTest
+        ? await fetch(`http://localhost:8080/api/model-configs/${editingId}/test`, {
+            method: 'POST',
+          })
+        : await fetch('http://localhost:8080/api/model-configs/test', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({
+              baseUrl: formData.baseUrl,
+              modelId: formData.modelId,
+              apiKey: formData.apiKey,
+            }),
+          });
 
       const data = await res.json();
       setTestResult(data);
+      if (savedTest) {
+        fetchConfigs();
+      }
     } catch (_err) {
       setTestResult({
         success: false,
@@ -155,6 +192,33 @@ export function ModelConfigPanel() {
     }
   };
 
+  const handleDiscover = async () => {
+    setDiscovering(true);
+    setError(null);
+
+    try {
+      const res = await fetch('http://localhost:8080/api/model-configs/discover', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+          baseUrl: formData.baseUrl,
+          apiKey: formData.apiKey,
+          modelConfigId: editingId ?? undefined,
+        }),
+      });
+
+      const data = await res.json();
+      if (!res.ok) {
+        throw new Error(data.error || 'Failed to fetch models');
+      }
+      setDiscoveredModels(data.models.map((m: { id: string }) => m.id));
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to fetch models');
+    } finally {
+      setDiscovering(false);
+    }
+  };
+
   const resetForm = () => {
     setFormData({
       name: '',
@@ -167,6 +231,7 @@ export function ModelConfigPanel() {
     setEditingId(null);
     setShowForm(false);
     setTestResult(null);
+    setDiscoveredModels([]);
     setError(null);
   };
 
@@ -258,13 +323,34 @@ export function ModelConfigPanel() {
                 <label htmlFor="modelId" className="mb-1 block font-medium text-sm">
                   Model ID *
                 </label>
-                <Input
-                  id="modelId"
-                  value={formData.modelId}
-                  onChange={(e) => setFormData({ ...formData, modelId: e.target.value })}
-                  placeholder="gpt-4o"
-                  required
-                />
+                <div className="flex gap-2">
+                  <Input
+                    id="modelId"
+                    list="modelIdOptions"
+                    value={formData.modelId}
+                    onChange={(e) => setFormData({ ...formData, modelId: e.target.value })}
+                    placeholder="gpt-4o"
+                    required
+                  />
+                  <Button
+                    type="button"
+                    onClick={handleDiscover}
+                    disabled={discovering}
+                    variant="outline"
+                  >
+                    {discovering ? <Loader2 className="h-4 w-4 animate-spin" /> : 'Fetch models'}
+                  </Button>
+                </div>
+                <datalist id="modelIdOptions">
+                  {discoveredModels.map((id) => (
+                    <option key={id} value={id} />
+                  ))}
+                </datalist>
+                {discoveredModels.length > 0 && (
+                  <p className="mt-1 text-muted-foreground text-xs">
+                    {discoveredModels.length} models available from this provider
+                  </p>
+                )}
               </div>
 
               <div>
@@ -305,7 +391,20 @@ export function ModelConfigPanel() {
                       : 'border-red-200 bg-red-50 text-red-800'
                   )}
                 >
-                  {testResult.message}
+                  <div>{testResult.message}</div>
+                  {testResult.errorDetails && (
+                    <div className="mt-1 text-xs">{testResult.errorDetails}</div>
+                  )}
+                  {testResult.checks && testResult.checks.length > 0 && (
+                    <ul className="mt-2 space-y-1 text-xs">
+                      {testResult.checks.map((check) => (
+                        <li key={check.name} title={check.error}>
+                          {check.supported ? '✓' : '✗'} {checkLabels[check.name] ?? check.name} ({check.latencyMs} ms)
+                          {!check.supported && check.errorClass && ` - ${check.errorClass}`}
+                        </li>
+                      ))}
+                    </ul>
+                  )}
                 </div>
               )}
 
@@ -364,6 +463,15 @@ export function ModelConfigPanel() {
                     <div>Provider: {config.provider}</div>
                     <div>Model: {config.modelId}</div>
                     {config.baseUrl && <div>Base URL: {config.baseUrl}</div>}
+                    {config.lastTestedAt && (
+                      <div
+                        className={config.lastTestSuccess ? 'text-green-700' : 'text-red-700'}
+                        title={config.lastTestMessage}
+                      >
+                        Last test: {config.lastTestSuccess ? 'passed' : 'failed'} (
+                        {new Date(config.lastTestedAt).toLocaleString()})
+                      </div>
+                    )}
                   </div>
                 </div>
                 <div className="flex gap-2">